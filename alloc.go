@@ -0,0 +1,43 @@
+package safe_treap
+
+// Allocator provides the nodes created by the mutations of a Treap, so that
+// embedders can serve them from an arena or a pool, or instrument them.
+//
+// NewNode must return a zeroed node.  Free is only given nodes that were
+// allocated and then discarded within a single operation, before any root
+// could reference them (e.g. the intermediate copy replaced by a rotation).
+// Nodes that were part of a returned root are never passed to Free, since the
+// treap cannot know when the last version sharing them is dropped.
+type Allocator interface {
+	NewNode() *Node
+	Free(*Node)
+}
+
+// WithAllocator makes the treap allocate its nodes from a.
+func WithAllocator(a Allocator) Option {
+	return func(t *Treap) {
+		t.allocator = a
+	}
+}
+
+func (t *Treap) newNode() *Node {
+	var n *Node
+	if t.allocator != nil {
+		n = t.allocator.NewNode()
+	} else {
+		n = new(Node)
+	}
+	if t.counters != nil {
+		t.counters.allocations++
+		if t.counters.allocated != nil {
+			t.counters.allocated[n] = struct{}{}
+		}
+	}
+	return n
+}
+
+func (t *Treap) free(n *Node) {
+	if t.allocator != nil {
+		t.allocator.Free(n)
+	}
+}
@@ -0,0 +1,49 @@
+package safe_treap
+
+import "fmt"
+
+// OpKind is the kind of change performed by an Op.
+type OpKind int
+
+const (
+	// OpPut inserts the key, or replaces the item and weight of the key.
+	OpPut OpKind = iota
+	// OpDelete removes the key if present.
+	OpDelete
+	// OpInsert inserts the key with the item and weight unless present.
+	OpInsert
+)
+
+// Op is a single change in a batch passed to Apply.
+type Op struct {
+	Kind   OpKind
+	Key    interface{}
+	Value  interface{}
+	Weight int // ignored by OpDelete
+}
+
+// Apply performs ops in order on n and returns the resulting root.  n itself
+// is never modified, so the batch is atomic: readers see either n or the
+// returned root, never an intermediate state.
+//
+// Each op is checked like InsertChecked against the size and depth limits of
+// t.  If one fails, Apply returns n and the error of that op, so a batch is
+// applied either entirely or not at all.
+func (t *Treap) Apply(n *Node, ops []Op) (*Node, error) {
+	res := n
+	for i, op := range ops {
+		var err error
+		switch op.Kind {
+		case OpPut:
+			res, _, err = t.UpsertChecked(res, op.Key, op.Value, op.Weight)
+		case OpDelete:
+			res, _, err = t.DeleteChecked(res, op.Key)
+		case OpInsert:
+			res, _, err = t.InsertChecked(res, op.Key, op.Value, op.Weight)
+		}
+		if err != nil {
+			return n, fmt.Errorf("op %d: %w", i, err)
+		}
+	}
+	return res, nil
+}
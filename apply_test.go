@@ -0,0 +1,43 @@
+package safe_treap
+
+import "testing"
+
+func TestApply(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 10)
+	res, err := tr.Apply(root, []Op{
+		{Kind: OpPut, Key: 1, Value: "put", Weight: -1},
+		{Kind: OpInsert, Key: 2, Value: "ignored"},
+		{Kind: OpInsert, Key: 20, Value: "inserted", Weight: 7},
+		{Kind: OpDelete, Key: 3},
+		{Kind: OpDelete, Key: 30},
+		{Kind: OpPut, Key: 20, Value: "replaced", Weight: 8},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Validate(res); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, _ := tr.GetNode(res, 1); n.Item != "put" || n.Weight != -1 || res.Key != 1 {
+		t.Errorf("OpPut stored %v with weight %d", n.Item, n.Weight)
+	}
+	if v, _ := tr.Get(res, 2); v != 20 {
+		t.Errorf("OpInsert replaced an existing key with %v", v)
+	}
+	if n, _ := tr.GetNode(res, 20); n.Item != "replaced" || n.Weight != 8 {
+		t.Errorf("key 20 holds %v with weight %d", n.Item, n.Weight)
+	}
+	if _, ok := tr.Get(res, 3); ok || tr.Len(res) != 10 {
+		t.Errorf("OpDelete left key 3, Len = %d", tr.Len(res))
+	}
+
+	// the input root is untouched.
+	if v, _ := tr.Get(root, 1); v != 10 || tr.Len(root) != 10 {
+		t.Error("Apply modified its input")
+	}
+	if res, err := tr.Apply(root, nil); res != root || err != nil {
+		t.Error("an empty batch changed the root")
+	}
+}
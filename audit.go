@@ -0,0 +1,163 @@
+package safe_treap
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BalanceReport is the outcome of sampling the shape of a treap.
+type BalanceReport struct {
+	// Samples is the number of random descents the report is based on.
+	Samples int
+	// Size estimates the number of nodes.
+	Size float64
+	// MeanDepth and MaxDepth are the depths of the leaves reached.
+	MeanDepth float64
+	MaxDepth  int
+	// Score is the ideal depth of a tree of Size nodes divided by MeanDepth,
+	// capped to 1: close to 1 for balanced trees, random treaps included,
+	// and near 0 for degenerate shapes such as lists.
+	Score float64
+}
+
+// Audit samples the shape of n with the given number of random descents from
+// the root to a leaf, taking one child at random at every node.  It costs
+// O(samples * depth), whatever the size of n.
+//
+// The size is estimated by weighting every level reached by the inverse of
+// the probability of reaching it (Knuth's estimator), which is unbiased.
+func (t *Treap) Audit(n *Node, samples int) BalanceReport {
+	return audit(n, samples, rand.Intn)
+}
+
+func audit(n *Node, samples int, intn func(int) int) BalanceReport {
+	r := BalanceReport{Samples: samples}
+	if n == nil || samples <= 0 {
+		r.Score = 1
+		return r
+	}
+
+	var depths int
+	for i := 0; i < samples; i++ {
+		depth, size, paths := 0, 0.0, 1.0
+		for c := n; c != nil; depth++ {
+			size += paths
+			switch {
+			case c.Left == nil:
+				c = c.Right
+			case c.Right == nil:
+				c = c.Left
+			default:
+				paths *= 2
+				if intn(2) == 0 {
+					c = c.Left
+				} else {
+					c = c.Right
+				}
+			}
+		}
+		depths += depth
+		r.Size += size
+		if depth > r.MaxDepth {
+			r.MaxDepth = depth
+		}
+	}
+
+	r.Size /= float64(samples)
+	r.MeanDepth = float64(depths) / float64(samples)
+	r.Score = math.Min(1, math.Log2(r.Size+1)/r.MeanDepth)
+	return r
+}
+
+// defaultAuditInterval is the interval used when AuditOptions.Interval is not
+// positive.
+const defaultAuditInterval = time.Minute
+
+// AuditOptions configures an Auditor.
+type AuditOptions struct {
+	// Interval is the time between audits, a minute if not positive.
+	Interval time.Duration
+	// Samples is the number of descents per audit.
+	Samples int
+	// Threshold is the Score below which the treap is degraded.
+	Threshold float64
+	// OnDegraded, if not nil, is called from the auditor's goroutine when an
+	// audit finds the treap degraded after finding it healthy (or on the
+	// first audit).
+	OnDegraded func(BalanceReport)
+}
+
+// Auditor audits the current root of a treap in the background, so that
+// degenerate shapes are noticed before they show up as latency.
+type Auditor struct {
+	t    *Treap
+	root func() *Node
+	opts AuditOptions
+
+	report atomic.Value
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewAuditor starts auditing the roots returned by root, which is called
+// from the auditor's goroutine and must be safe to call concurrently with
+// writers.
+func (t *Treap) NewAuditor(root func() *Node, opts AuditOptions) *Auditor {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultAuditInterval
+	}
+	a := &Auditor{
+		t:    t,
+		root: root,
+		opts: opts,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+func (a *Auditor) run() {
+	defer close(a.done)
+
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	ticker := time.NewTicker(a.opts.Interval)
+	defer ticker.Stop()
+
+	healthy := true
+	for {
+		r := audit(a.root(), a.opts.Samples, rnd.Intn)
+		a.report.Store(r)
+		if r.Score < a.opts.Threshold {
+			if healthy && a.opts.OnDegraded != nil {
+				a.opts.OnDegraded(r)
+			}
+			healthy = false
+		} else {
+			healthy = true
+		}
+
+		select {
+		case <-ticker.C:
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// Health returns the report of the latest audit, and false if none completed
+// yet.
+func (a *Auditor) Health() (BalanceReport, bool) {
+	r, ok := a.report.Load().(BalanceReport)
+	return r, ok
+}
+
+// Stop stops the auditor, waiting for a running audit to complete.
+func (a *Auditor) Stop() {
+	a.once.Do(func() { close(a.stop) })
+	<-a.done
+}
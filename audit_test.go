@@ -0,0 +1,72 @@
+package safe_treap
+
+import (
+	"math"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAudit(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 1000)
+	r := tr.Audit(root, 2000)
+	if r.Samples != 2000 || r.Score < 0.5 {
+		t.Errorf("a random treap scored %v", r.Score)
+	}
+	if math.Abs(r.Size-1000) > 300 {
+		t.Errorf("estimated %v nodes, want about 1000", r.Size)
+	}
+	if r.MaxDepth < int(r.MeanDepth) || r.MaxDepth > 100 {
+		t.Errorf("MaxDepth = %d, MeanDepth = %v", r.MaxDepth, r.MeanDepth)
+	}
+
+	// a list holds a single path, measured exactly.
+	_, list := chain(t, 100, 0)
+	r = tr.Audit(list, 10)
+	if r.Size != 100 || r.MaxDepth != 100 || r.Score > 0.1 {
+		t.Errorf("a list of 100 nodes: %+v", r)
+	}
+
+	if r := tr.Audit(nil, 10); r.Score != 1 || r.Size != 0 {
+		t.Errorf("an empty treap: %+v", r)
+	}
+}
+
+func TestAuditor(t *testing.T) {
+	tr := NewIntTreap()
+	_, list := chain(t, 100, 0)
+	var root atomic.Pointer[Node]
+	root.Store(list)
+
+	degraded := make(chan BalanceReport, 10)
+	a := tr.NewAuditor(root.Load, AuditOptions{
+		Interval:   time.Millisecond,
+		Samples:    10,
+		Threshold:  0.5,
+		OnDegraded: func(r BalanceReport) { degraded <- r },
+	})
+	defer a.Stop()
+
+	if r := <-degraded; r.MaxDepth != 100 {
+		t.Errorf("OnDegraded got %+v", r)
+	}
+	waitFor(t, func() bool {
+		r, ok := a.Health()
+		return ok && r.Score < 0.5
+	})
+
+	// recovering and degrading again reports once more.
+	root.Store(fill(t, tr, 100))
+	waitFor(t, func() bool {
+		r, _ := a.Health()
+		return r.Score >= 0.5
+	})
+	root.Store(list)
+	<-degraded
+
+	a.Stop()
+	if len(degraded) > 0 {
+		t.Errorf("OnDegraded was called %d times for a single degradation", len(degraded))
+	}
+}
@@ -0,0 +1,68 @@
+package safe_treap
+
+import "sort"
+
+// InsertBatch inserts the pairs whose keys are not already in n, returning the
+// new root and the number of keys inserted.  Like successive calls to
+// InsertChecked, it keeps existing keys with their items and weights, keeps
+// the first of duplicated pairs and fails, leaving n untouched, if a pair
+// exceeds the size or depth limits of t.  But it sorts the batch, builds it
+// into a treap, drops the keys already in n and unions the rest with n, so
+// path copies are shared between the keys of the batch: O(m log(n/m)) for m
+// pairs instead of O(m log n).
+func (t *Treap) InsertBatch(n *Node, pairs []KV) (*Node, int, error) {
+	nodes := make([]*Node, len(pairs))
+	for i, p := range pairs {
+		if err := t.checkSizes(p.Key, p.Value); err != nil {
+			return n, 0, err
+		}
+		c := t.newNode()
+		c.Weight, c.Key, c.Item = t.randomWeight(), p.Key, p.Value
+		nodes[i] = c
+	}
+
+	added := t.Difference(t.buildBatch(nodes), n)
+	res := t.Union(n, added)
+	if t.maxDepth > 0 {
+		var err error
+		ascend(added, func(c *Node) bool {
+			err = t.checkDepth(res, c.Key, false)
+			return err == nil
+		})
+		if err != nil {
+			return n, 0, err
+		}
+	}
+	return res, nodeSize(added), nil
+}
+
+// DeleteBatch removes keys from n, returning the new root and the number of
+// keys removed.  As in InsertBatch, the keys are sorted, built into a treap and
+// subtracted from n in one divide-and-conquer pass.
+func (t *Treap) DeleteBatch(n *Node, keys []interface{}) (*Node, int) {
+	nodes := make([]*Node, len(keys))
+	for i, k := range keys {
+		c := t.newNode()
+		c.Weight, c.Key = t.randomWeight(), k
+		nodes[i] = c
+	}
+
+	res := t.Difference(n, t.buildBatch(nodes))
+	return res, nodeSize(n) - nodeSize(res)
+}
+
+// buildBatch builds fresh childless nodes into a treap, keeping the first of
+// the nodes with equal keys.
+func (t *Treap) buildBatch(nodes []*Node) *Node {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return t.h().CompareKeys(nodes[i].Key, nodes[j].Key) < 0
+	})
+
+	b := newBuilder(t.h())
+	for i, n := range nodes {
+		if i == 0 || t.h().CompareKeys(nodes[i-1].Key, n.Key) != 0 {
+			b.push(n)
+		}
+	}
+	return b.root()
+}
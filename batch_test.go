@@ -0,0 +1,73 @@
+package safe_treap
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInsertBatch(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 100)
+
+	pairs := []KV{{150, "a"}, {50, "existing"}, {120, "b"}, {150, "duplicate"}, {-1, "c"}}
+	res, added, err := tr.InsertBatch(root, pairs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Validate(res); err != nil {
+		t.Fatal(err)
+	}
+	if added != 3 || tr.Len(res) != 103 {
+		t.Errorf("added %d keys, Len = %d", added, tr.Len(res))
+	}
+	if v, _ := tr.Get(res, 150); v != "a" {
+		t.Errorf("Get(150) = %v, want the first of the duplicates", v)
+	}
+
+	// existing keys keep their nodes and weights.
+	for k := 0; k < 100; k++ {
+		old, _ := tr.GetNode(root, k)
+		n, _ := tr.GetNode(res, k)
+		if n.Item != old.Item || n.Weight != old.Weight {
+			t.Errorf("key %d changed from %v/%d to %v/%d", k, old.Item, old.Weight, n.Item, n.Weight)
+		}
+	}
+
+	if res, added, err := tr.InsertBatch(root, []KV{{1, "x"}, {2, "y"}}); res != root || added != 0 || err != nil {
+		t.Errorf("a batch of existing keys copied the treap: %d, %v", added, err)
+	}
+}
+
+func TestInsertBatchLimits(t *testing.T) {
+	tr := newLimitedTreap(t)
+	root, _, _ := tr.InsertBatch(nil, []KV{{"a", "1"}})
+	if res, _, err := tr.InsertBatch(root, []KV{{"b", "2"}, {"large", "3"}}); !errors.Is(err, ErrKeyTooLarge) || res != root {
+		t.Errorf("InsertBatch of an oversized key: %v", err)
+	}
+
+	deep, spine := chain(t, 10, 10)
+	// a random weight sends 10 below the bottom of the spine.
+	if res, _, err := deep.InsertBatch(spine, []KV{{-1, 0}, {10, 0}}); err != ErrDepthExceeded || res != spine {
+		t.Errorf("InsertBatch past the depth limit: %v", err)
+	}
+	if _, _, err := deep.InsertBatch(spine, []KV{{5, 0}}); err != nil {
+		t.Errorf("InsertBatch of an existing key: %v", err)
+	}
+}
+
+func TestDeleteBatch(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 100)
+	res, removed := tr.DeleteBatch(root, []interface{}{5, 500, 7, 5, 99})
+	if err := tr.Validate(res); err != nil {
+		t.Fatal(err)
+	}
+	if removed != 3 || tr.Len(res) != 97 {
+		t.Errorf("removed %d keys, Len = %d", removed, tr.Len(res))
+	}
+	for _, k := range []int{5, 7, 99} {
+		if _, ok := tr.Get(res, k); ok {
+			t.Errorf("key %d is still present", k)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package benchmarks
+
+import (
+	"math/rand"
+	"testing"
+
+	treap "github.com/fearblackcat/safe-treap"
+	"github.com/fearblackcat/safe-treap/typed"
+)
+
+// size is the number of keys of every benchmark, and rangeSize the number of
+// keys visited by a range scan.
+const (
+	size      = 10000
+	rangeSize = 100
+)
+
+// seed is the seed of the random source handed to the workload generators
+// and used to pick weights.
+const seed = 1
+
+func newTreap() *treap.Treap {
+	t, err := treap.NewTreap(&treap.Handle{
+		CompareKeys:    treap.IntComparator,
+		CompareWeights: treap.IntComparator,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// build inserts keys into an empty persistent treap with random weights.
+// Repeated keys keep their first value.
+func build(t *treap.Treap, rng *rand.Rand, keys []int) *treap.Node {
+	var root *treap.Node
+	for _, k := range keys {
+		if n, ok := t.Insert(root, k, k, rng.Int()); ok {
+			root = n
+		}
+	}
+	return root
+}
+
+// buildTransient is build editing a single Transient in place.
+func buildTransient(t *treap.Treap, rng *rand.Rand, keys []int) *treap.Node {
+	tx := t.Transient(nil)
+	for _, k := range keys {
+		tx.Insert(k, k, rng.Int())
+	}
+	return tx.Root()
+}
+
+// buildTyped is build with the specialized nodes of the typed package.
+func buildTyped(t *typed.Treap[int, int], rng *rand.Rand, keys []int) *typed.Node[int, int] {
+	var root *typed.Node[int, int]
+	for _, k := range keys {
+		root, _ = t.Insert(root, k, k, rng.Int())
+	}
+	return root
+}
+
+// workloads runs bench as one sub-benchmark per workload, with the keys of
+// the workload and a random source for the weights.
+func workloads(b *testing.B, bench func(b *testing.B, rng *rand.Rand, keys []int)) {
+	for _, w := range Workloads {
+		b.Run(w.Name, func(b *testing.B) {
+			rng := rand.New(rand.NewSource(seed))
+			keys := w.Keys(rng, size)
+			b.ReportAllocs()
+			bench(b, rng, keys)
+		})
+	}
+}
+
+// BenchmarkInsert builds a persistent treap from the keys of every workload;
+// one iteration inserts all of the keys into an empty treap.
+func BenchmarkInsert(b *testing.B) {
+	workloads(b, func(b *testing.B, rng *rand.Rand, keys []int) {
+		t := newTreap()
+		for i := 0; i < b.N; i++ {
+			build(t, rng, keys)
+		}
+	})
+}
+
+// BenchmarkTransientInsert is BenchmarkInsert through a Transient.
+func BenchmarkTransientInsert(b *testing.B) {
+	workloads(b, func(b *testing.B, rng *rand.Rand, keys []int) {
+		t := newTreap()
+		for i := 0; i < b.N; i++ {
+			buildTransient(t, rng, keys)
+		}
+	})
+}
+
+// BenchmarkTypedInsert is BenchmarkInsert with typed nodes.
+func BenchmarkTypedInsert(b *testing.B) {
+	workloads(b, func(b *testing.B, rng *rand.Rand, keys []int) {
+		t := typed.NewOrdered[int, int]()
+		for i := 0; i < b.N; i++ {
+			buildTyped(t, rng, keys)
+		}
+	})
+}
+
+// BenchmarkMapInsert is the mutable baseline for BenchmarkInsert: the same
+// keys stored in a builtin map, which keeps no history.
+func BenchmarkMapInsert(b *testing.B) {
+	workloads(b, func(b *testing.B, _ *rand.Rand, keys []int) {
+		for i := 0; i < b.N; i++ {
+			m := make(map[interface{}]interface{})
+			for _, k := range keys {
+				m[k] = k
+			}
+		}
+	})
+}
+
+// BenchmarkGet looks up the keys of every workload in a treap holding them;
+// one iteration is a single lookup.
+func BenchmarkGet(b *testing.B) {
+	workloads(b, func(b *testing.B, rng *rand.Rand, keys []int) {
+		t := newTreap()
+		root := build(t, rng, keys)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			t.Get(root, keys[i%len(keys)])
+		}
+	})
+}
+
+// BenchmarkTypedGet is BenchmarkGet with typed nodes.
+func BenchmarkTypedGet(b *testing.B) {
+	workloads(b, func(b *testing.B, rng *rand.Rand, keys []int) {
+		t := typed.NewOrdered[int, int]()
+		root := buildTyped(t, rng, keys)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			t.Get(root, keys[i%len(keys)])
+		}
+	})
+}
+
+// BenchmarkMapGet is the mutable baseline for BenchmarkGet.
+func BenchmarkMapGet(b *testing.B) {
+	workloads(b, func(b *testing.B, _ *rand.Rand, keys []int) {
+		m := make(map[interface{}]interface{})
+		for _, k := range keys {
+			m[k] = k
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = m[keys[i%len(keys)]]
+		}
+	})
+}
+
+// BenchmarkRange scans rangeSize consecutive keys starting from the keys of
+// every workload; one iteration is a single scan.
+func BenchmarkRange(b *testing.B) {
+	workloads(b, func(b *testing.B, rng *rand.Rand, keys []int) {
+		t := newTreap()
+		root := build(t, rng, keys)
+		dst := make([]treap.KV, 0, rangeSize)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			lo := keys[i%len(keys)]
+			dst = t.AppendRange(dst[:0], root, lo, lo+rangeSize-1)
+		}
+	})
+}
@@ -0,0 +1,57 @@
+// Package benchmarks provides workload generators and benchmarks for the
+// treap, so that the cost of changes to the mutation and read paths can be
+// measured on realistic key distributions.
+package benchmarks
+
+import "math/rand"
+
+// Workload generates the sequence of keys used by a benchmark.
+type Workload struct {
+	Name string
+
+	// Keys returns n keys drawn from the distribution, using rng as the
+	// only source of randomness so that runs are reproducible.
+	Keys func(rng *rand.Rand, n int) []int
+}
+
+// Uniform draws keys uniformly from [0, n).
+var Uniform = Workload{
+	Name: "uniform",
+	Keys: func(rng *rand.Rand, n int) []int {
+		keys := make([]int, n)
+		for i := range keys {
+			keys[i] = rng.Intn(n)
+		}
+		return keys
+	},
+}
+
+// Zipfian draws keys from [0, n) with a zipf distribution (s = 1.1), so that
+// a small set of hot keys receives most of the operations.
+var Zipfian = Workload{
+	Name: "zipfian",
+	Keys: func(rng *rand.Rand, n int) []int {
+		z := rand.NewZipf(rng, 1.1, 1, uint64(n-1))
+		keys := make([]int, n)
+		for i := range keys {
+			keys[i] = int(z.Uint64())
+		}
+		return keys
+	},
+}
+
+// Sequential produces the keys 0, 1, ..., n-1 in order, the shape of
+// time-ordered ingestion.
+var Sequential = Workload{
+	Name: "sequential",
+	Keys: func(_ *rand.Rand, n int) []int {
+		keys := make([]int, n)
+		for i := range keys {
+			keys[i] = i
+		}
+		return keys
+	},
+}
+
+// Workloads lists every generator in this package.
+var Workloads = []Workload{Uniform, Zipfian, Sequential}
@@ -0,0 +1,108 @@
+package safe_treap
+
+import "sort"
+
+// builder assembles a treap from nodes pushed in ascending key order.
+//
+// It keeps the right spine of the tree built so far on a stack, which makes
+// the whole build O(n).  Pushed nodes must be freshly allocated: the builder
+// rewires their children and they must not be shared with another root until
+// root has been called.
+type builder struct {
+	handle *Handle
+	spine  []*Node
+}
+
+func newBuilder(h *Handle) *builder {
+	return &builder{handle: h}
+}
+
+// push appends n, whose key must be greater than every key pushed before.
+func (b *builder) push(n *Node) {
+	var last *Node
+	for len(b.spine) > 0 {
+		top := b.spine[len(b.spine)-1]
+		if b.handle.CompareWeights(top.Weight, n.Weight) <= 0 {
+			top.Right = n
+			break
+		}
+		last = top
+		b.spine = b.spine[:len(b.spine)-1]
+		resize(last) // its subtree is complete once off the spine
+	}
+	n.Left = last
+	b.spine = append(b.spine, n)
+}
+
+// root returns the root of the treap built so far.
+func (b *builder) root() *Node {
+	if len(b.spine) == 0 {
+		return nil
+	}
+	for i := len(b.spine) - 1; i >= 0; i-- {
+		resize(b.spine[i])
+	}
+	return b.spine[0]
+}
+
+// buildUnsorted builds a treap of fresh childless nodes, sorting them by key
+// first unless they are already sorted.  If two nodes have the same key it
+// returns one of them as dup instead.
+func (t *Treap) buildUnsorted(nodes []*Node) (root, dup *Node) {
+	less := func(i, j int) bool { return t.h().CompareKeys(nodes[i].Key, nodes[j].Key) < 0 }
+	if !sort.SliceIsSorted(nodes, less) {
+		sort.SliceStable(nodes, less)
+	}
+
+	b := newBuilder(t.h())
+	for i, n := range nodes {
+		if i > 0 && t.h().CompareKeys(nodes[i-1].Key, n.Key) == 0 {
+			return nil, n
+		}
+		b.push(n)
+	}
+	return b.root(), nil
+}
+
+// FromPairsWithWeights bulk-builds a treap of records keeping the weight of
+// every record as given, in any key order.  Keys must be distinct; a
+// duplicate yields a *KeyExistsError.
+//
+// Distinct keys and their weights determine a single treap, so two replicas
+// given the same records end up with the same shape, whatever order the
+// records come in.  This holds for equal weights too: of two keys with equal
+// weights, the lesser one is always the ancestor, as the build breaks ties by
+// key order.
+//
+// O(n) if the records are sorted by key, O(n log n) otherwise.
+func (t *Treap) FromPairsWithWeights(recs []Record) (*Node, error) {
+	nodes := make([]*Node, len(recs))
+	for i, r := range recs {
+		n := t.newNode()
+		n.Key, n.Item, n.Weight = r.Key, r.Item, r.Weight
+		nodes[i] = n
+	}
+
+	root, dup := t.buildUnsorted(nodes)
+	if dup != nil {
+		return nil, &KeyExistsError{Key: dup.Key}
+	}
+	return root, nil
+}
+
+// BuildFromSorted builds a treap of pairs, which must be sorted by strictly
+// increasing key, in O(n) instead of the O(n log n) of inserting them one by
+// one.  Keys get random weights (see Put), so the treap is as balanced as one
+// built by insertion.  Pairs out of order yield ErrUnsorted.
+func (t *Treap) BuildFromSorted(pairs []KV) (*Node, error) {
+	b := newBuilder(t.h())
+	for i, p := range pairs {
+		if i > 0 && t.h().CompareKeys(pairs[i-1].Key, p.Key) >= 0 {
+			return nil, ErrUnsorted
+		}
+		n := t.newNode()
+		n.Weight, n.Key, n.Item = t.randomWeight(), p.Key, p.Value
+		b.push(n)
+	}
+	return b.root(), nil
+}
@@ -0,0 +1,191 @@
+package safe_treap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+var bundleMagic = []byte("TRPBNDL1")
+
+// ErrBadBundle is returned when a stream is not a bundle or is truncated.
+var ErrBadBundle = errors.New("malformed treap bundle")
+
+// Bundle serializes several named treaps, e.g. a primary index and its
+// secondary indexes, as a single unit so that they are always restored
+// together from the same point in time.
+//
+// Every treap is written in key order with Codec, framed with its name and
+// length.
+type Bundle struct {
+	Codec Codec
+}
+
+// Write serializes the treaps in roots to w, ordered by name.
+func (b Bundle) Write(w io.Writer, roots map[string]*Node) error {
+	names := make([]string, 0, len(roots))
+	for name := range roots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	bw.Write(bundleMagic)
+	writeUvarint(bw, uint64(len(names)))
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		buf.Reset()
+		if err := encodeSorted(b.Codec.NewEncoder(&buf), roots[name]); err != nil {
+			return err
+		}
+
+		writeUvarint(bw, uint64(len(name)))
+		bw.WriteString(name)
+		writeUvarint(bw, uint64(buf.Len()))
+		bw.Write(buf.Bytes())
+	}
+
+	return bw.Flush()
+}
+
+// WriteFile atomically replaces the file at path with the bundle of roots (see
+// writeFileAtomic).
+func (b Bundle) WriteFile(path string, roots map[string]*Node) error {
+	return writeFileAtomic(path, func(w io.Writer) error {
+		return b.Write(w, roots)
+	})
+}
+
+// writeFileAtomic replaces the file at path with the output of write: it is
+// written and synced to a temporary file in the same directory, which is then
+// renamed over path, and the directory is synced so that the rename survives
+// a crash.
+func writeFileAtomic(path string, write func(io.Writer) error) (err error) {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if err = write(f); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir flushes the entries of the directory at path to disk.
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Read restores a bundle written by Write.  Every treap in the bundle is
+// decoded with the Treap registered under its name in treaps, whose handle
+// must order its keys; a name without a Treap is an error, and so is a name
+// appearing twice, which Write never produces.
+func (b Bundle) Read(r io.Reader, treaps map[string]*Treap) (map[string]*Node, error) {
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(bundleMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || !bytes.Equal(magic, bundleMagic) {
+		return nil, ErrBadBundle
+	}
+
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, ErrBadBundle
+	}
+
+	// the map is not sized by count, which comes from the input.
+	roots := make(map[string]*Node)
+	for i := uint64(0); i < count; i++ {
+		name, err := readBytes(br)
+		if err != nil {
+			return nil, err
+		}
+		data, err := readBytes(br)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := roots[string(name)]; ok {
+			return nil, fmt.Errorf("%w: duplicate entry %q", ErrBadBundle, name)
+		}
+		t, ok := treaps[string(name)]
+		if !ok {
+			return nil, fmt.Errorf("no treap for bundle entry %q", name)
+		}
+		if roots[string(name)], err = t.DecodeSorted(bytes.NewReader(data), b.Codec); err != nil {
+			return nil, fmt.Errorf("bundle entry %q: %w", name, err)
+		}
+	}
+
+	return roots, nil
+}
+
+// ReadFile restores the bundle stored at path (see Read).
+func (b Bundle) ReadFile(path string, treaps map[string]*Treap) (map[string]*Node, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return b.Read(f, treaps)
+}
+
+// encodeSorted writes the entries of n to enc in key order.
+func encodeSorted(enc Encoder, n *Node) (err error) {
+	ascend(n, func(n *Node) bool {
+		err = enc.Encode(&Record{Key: n.Key, Item: n.Item, Weight: n.Weight})
+		return err == nil
+	})
+	return
+}
+
+func writeUvarint(w *bufio.Writer, x uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	w.Write(buf[:binary.PutUvarint(buf[:], x)])
+}
+
+// readBytes reads a length-prefixed string.  The buffer grows with the bytes
+// actually read, so a damaged length cannot allocate more than the input
+// holds.
+func readBytes(r *bufio.Reader) ([]byte, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil || size > math.MaxInt64 {
+		return nil, ErrBadBundle
+	}
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(size)); err != nil {
+		return nil, ErrBadBundle
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,70 @@
+package safe_treap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	ints, strs := NewIntTreap(), NewStringTreap()
+	roots := map[string]*Node{"ints": fill(t, ints, 300), "empty": nil}
+	roots["strs"], _ = strs.Put(nil, "a", 1)
+	roots["strs"], _ = strs.Put(roots["strs"], "b", 2)
+	treaps := map[string]*Treap{"ints": ints, "strs": strs, "empty": ints}
+
+	path := filepath.Join(t.TempDir(), "bundle")
+	b := Bundle{Codec: GobCodec}
+	if err := b.WriteFile(path, roots); err != nil {
+		t.Fatal(err)
+	}
+	got, err := b.ReadFile(path, treaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != len(roots) {
+		t.Fatalf("read %d treaps, want %d", len(got), len(roots))
+	}
+	for name, want := range roots {
+		tr := treaps[name]
+		if err := tr.Validate(got[name]); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if added, removed, changed := tr.Diff(want, got[name]); len(added)+len(removed)+len(changed) != 0 {
+			t.Errorf("%s differs: +%v -%v ~%v", name, added, removed, changed)
+		}
+	}
+}
+
+func TestBundleMalformed(t *testing.T) {
+	var good bytes.Buffer
+	if err := (Bundle{Codec: GobCodec}).Write(&good, map[string]*Node{"ints": fill(t, NewIntTreap(), 10)}); err != nil {
+		t.Fatal(err)
+	}
+	header := append([]byte(nil), bundleMagic...)
+	entry := good.Bytes()[len(header)+1:]
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"magic", []byte("NOTABNDL")},
+		{"truncated", good.Bytes()[:good.Len()-1]},
+		{"huge name", append(binary.AppendUvarint(header, 1), binary.AppendUvarint(nil, 1<<62)...)},
+		{"overflowing name", append(binary.AppendUvarint(header, 1), binary.AppendUvarint(nil, 1<<63+1)...)},
+		{"huge count", binary.AppendUvarint(header, 1<<30)},
+		{"overflowing count", binary.AppendUvarint(header, 1<<63+1)},
+		{"duplicate name", append(append(binary.AppendUvarint(header, 2), entry...), entry...)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Bundle{Codec: GobCodec}.Read(bytes.NewReader(tc.data), map[string]*Treap{"ints": NewIntTreap()})
+			if !errors.Is(err, ErrBadBundle) {
+				t.Errorf("err = %v, want ErrBadBundle", err)
+			}
+		})
+	}
+}
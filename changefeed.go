@@ -0,0 +1,193 @@
+package safe_treap
+
+import (
+	"errors"
+	"iter"
+	"sync"
+)
+
+// ErrVersionUnavailable is returned by Follow for a version whose later changes
+// were discarded with Truncate, or that the feed has not reached.
+var ErrVersionUnavailable = errors.New("changes since version are not available")
+
+// Change is a batch of operations applied by a ChangeFeed, with the version
+// it produced.
+type Change struct {
+	Version uint64
+	Ops     []Op
+	Stats   ChangeStats
+}
+
+// ChangeStats summarizes what changes did to a treap, to monitor the
+// workload without instrumenting the writers.
+type ChangeStats struct {
+	// Added, Removed and Updated count the keys created, deleted and given
+	// another item.  Writes leaving every item as it was are not counted.
+	Added, Removed, Updated int
+	// Bytes estimates the data churned: the keys and items added and
+	// removed, and both the old and new items of the updated keys, with
+	// strings and byte slices counting for their length and other values
+	// for nothing.
+	Bytes int
+}
+
+func (s *ChangeStats) add(o ChangeStats) {
+	s.Added += o.Added
+	s.Removed += o.Removed
+	s.Updated += o.Updated
+	s.Bytes += o.Bytes
+}
+
+// ChangeFeed is the changelog of a treap: it applies batches of operations to
+// its root, numbering the versions they produce, and keeps the batches so
+// that subscribers can catch up on them.  A new subscriber bootstraps from
+// Snapshot, which returns a root together with its version, and then follows
+// the changes since that version: it sees every later change exactly once,
+// whether it was applied before or after the subscriber caught up.
+//
+// Changes are kept until Truncate discards them.  A ChangeFeed is safe for
+// concurrent use.
+type ChangeFeed struct {
+	t *Treap
+
+	mu      sync.Mutex
+	root    *Node
+	version uint64
+	changes []Change      // up to version, without gaps
+	stats   ChangeStats   // of every change applied
+	notify  chan struct{} // closed and replaced by every Apply, and by Close
+	closed  bool
+}
+
+// NewChangeFeed creates a feed of changes to root, ordered by t, starting at
+// version, e.g. the version of the checkpoint root was loaded from.
+func NewChangeFeed(t *Treap, root *Node, version uint64) *ChangeFeed {
+	return &ChangeFeed{t: t, root: root, version: version, notify: make(chan struct{})}
+}
+
+// Apply applies ops to the current root as a single change, as Apply on the
+// treap does, and returns the version it produced.  A failing batch changes
+// nothing and records no change.  Writes to a closed feed fail with ErrClosed
+// (see MisusePolicy).
+func (f *ChangeFeed) Apply(ops []Op) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return f.version, f.t.misuse(ErrClosed)
+	}
+	res, err := f.t.Apply(f.root, ops)
+	if err != nil {
+		return f.version, err
+	}
+
+	stats := f.changeStats(f.root, res)
+	f.root = res
+	f.version++
+	f.stats.add(stats)
+	f.changes = append(f.changes, Change{Version: f.version, Ops: append([]Op(nil), ops...), Stats: stats})
+	close(f.notify)
+	f.notify = make(chan struct{})
+	return f.version, nil
+}
+
+// changeStats diffs the roots before and after a change, in O(k log n) for k
+// changed keys.
+func (f *ChangeFeed) changeStats(old, res *Node) ChangeStats {
+	added, removed, changed := f.t.Diff(old, res)
+	s := ChangeStats{Added: len(added), Removed: len(removed), Updated: len(changed)}
+	for _, kvs := range [][]KV{added, removed} {
+		for _, kv := range kvs {
+			s.Bytes += approximateSize(kv.Key) + approximateSize(kv.Value)
+		}
+	}
+	for _, kv := range changed {
+		prev, _ := f.t.Get(old, kv.Key)
+		s.Bytes += approximateSize(prev) + approximateSize(kv.Value)
+	}
+	return s
+}
+
+// Stats returns the totals of the stats of every change applied by the feed.
+func (f *ChangeFeed) Stats() ChangeStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats
+}
+
+// Snapshot returns the current root and its version.
+func (f *ChangeFeed) Snapshot() (*Node, uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.root, f.version
+}
+
+// Truncate discards the changes up to version, which subscribers that are
+// behind it can no longer follow.
+func (f *ChangeFeed) Truncate(version uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	drop := len(f.changes) - int(f.version-min(version, f.version))
+	if drop > 0 {
+		f.changes = append([]Change(nil), f.changes[drop:]...)
+	}
+}
+
+// Close ends the iterations of Follow once they caught up, and makes later
+// writes fail.
+func (f *ChangeFeed) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.closed {
+		f.closed = true
+		close(f.notify)
+	}
+}
+
+// Follow returns an iterator over the changes after version: the recorded
+// ones first, then every new change as it is applied, until the feed is
+// closed or stop is closed.  If the changes after version are not available
+// it yields a single ErrVersionUnavailable.  The Ops of a change must not be
+// modified.
+func (f *ChangeFeed) Follow(version uint64, stop <-chan struct{}) iter.Seq2[Change, error] {
+	return func(yield func(Change, error) bool) {
+		for {
+			c, ok, wait, err := f.next(version)
+			switch {
+			case err != nil:
+				yield(Change{}, err)
+				return
+			case ok:
+				if !yield(c, nil) {
+					return
+				}
+				version = c.Version
+			case wait == nil:
+				return // closed
+			default:
+				select {
+				case <-wait:
+				case <-stop:
+					return
+				}
+			}
+		}
+	}
+}
+
+// next returns the change after version if there is one, and otherwise the
+// channel closed by the next change, or nil once the feed is closed.
+func (f *ChangeFeed) next(version uint64) (c Change, ok bool, wait chan struct{}, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	first := f.version - uint64(len(f.changes)) // version before changes[0]
+	switch {
+	case version < first || version > f.version:
+		return Change{}, false, nil, ErrVersionUnavailable
+	case version < f.version:
+		return f.changes[version-first], true, nil, nil
+	case f.closed:
+		return Change{}, false, nil, nil
+	}
+	return Change{}, false, f.notify, nil
+}
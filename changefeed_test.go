@@ -0,0 +1,154 @@
+package safe_treap
+
+import "testing"
+
+func put(k int) []Op {
+	return []Op{{Kind: OpPut, Key: k, Value: k * 10}}
+}
+
+func TestChangeFeed(t *testing.T) {
+	tr := NewIntTreap()
+	f := NewChangeFeed(tr, nil, 100)
+	for k := 0; k < 5; k++ {
+		if v, err := f.Apply(put(k)); err != nil || v != uint64(101+k) {
+			t.Fatalf("Apply = %d, %v", v, err)
+		}
+	}
+
+	// a subscriber bootstrapping from a snapshot taken in the middle.
+	root, version := f.Snapshot()
+	f.Apply(put(5))
+	go func() {
+		for k := 6; k < 10; k++ {
+			f.Apply(put(k))
+		}
+		f.Close()
+	}()
+
+	for c, err := range f.Follow(version, nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Version != version+1 {
+			t.Fatalf("got version %d after %d", c.Version, version)
+		}
+		if root, err = tr.Apply(root, c.Ops); err != nil {
+			t.Fatal(err)
+		}
+		version = c.Version
+	}
+
+	final, last := f.Snapshot()
+	if version != last || version != 110 || tr.Len(root) != 10 {
+		t.Errorf("caught up to version %d of %d with %d keys", version, last, tr.Len(root))
+	}
+	if added, removed, changed := tr.Diff(root, final); len(added)+len(removed)+len(changed) != 0 {
+		t.Errorf("the subscriber built another treap: +%v -%v ~%v", added, removed, changed)
+	}
+	if _, err := f.Apply(put(0)); err != ErrClosed {
+		t.Errorf("Apply after Close: %v", err)
+	}
+}
+
+func TestChangeFeedStop(t *testing.T) {
+	f := NewChangeFeed(NewIntTreap(), nil, 0)
+	f.Apply(put(1))
+	stop := make(chan struct{})
+	done := make(chan int)
+	go func() {
+		n := 0
+		for range f.Follow(0, stop) {
+			n++
+		}
+		done <- n
+	}()
+
+	f.Apply(put(2))
+	// recorded changes are yielded before stop is looked at.
+	close(stop)
+	if n := <-done; n != 2 {
+		t.Errorf("followed %d changes, want 2", n)
+	}
+}
+
+func TestChangeFeedTruncate(t *testing.T) {
+	f := NewChangeFeed(NewIntTreap(), nil, 0)
+	for k := 0; k < 10; k++ {
+		f.Apply(put(k))
+	}
+	f.Truncate(4)
+	f.Close()
+
+	for _, v := range []uint64{3, 11} {
+		for _, err := range f.Follow(v, nil) {
+			if err != ErrVersionUnavailable {
+				t.Errorf("Follow(%d): %v", v, err)
+			}
+		}
+	}
+	var versions []uint64
+	for c, err := range f.Follow(4, nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, c.Version)
+	}
+	if len(versions) != 6 || versions[0] != 5 {
+		t.Errorf("followed versions %v", versions)
+	}
+
+	f.Truncate(10)
+	for range f.Follow(10, nil) {
+		t.Error("a change after the last version")
+	}
+}
+
+func TestChangeFeedFailedApply(t *testing.T) {
+	f := NewChangeFeed(newLimitedTreap(t), nil, 0)
+	if _, err := f.Apply([]Op{{Kind: OpPut, Key: "large", Value: ""}}); err == nil {
+		t.Fatal("an oversized key was applied")
+	}
+	if root, version := f.Snapshot(); root != nil || version != 0 {
+		t.Errorf("a failed batch produced version %d", version)
+	}
+}
+
+func TestChangeFeedStats(t *testing.T) {
+	tr := NewStringTreap()
+	f := NewChangeFeed(tr, nil, 0)
+	f.Apply([]Op{
+		{Kind: OpPut, Key: "a", Value: "1234"},
+		{Kind: OpPut, Key: "b", Value: "12"},
+		{Kind: OpPut, Key: "c", Value: 3},
+	})
+	f.Apply([]Op{
+		{Kind: OpPut, Key: "a", Value: "123"},
+		{Kind: OpDelete, Key: "b"},
+		{Kind: OpDelete, Key: "missing"},
+		{Kind: OpPut, Key: "c", Value: 3, Weight: 5}, // same item
+	})
+
+	var stats []ChangeStats
+	for c, err := range f.Follow(0, closed()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats = append(stats, c.Stats)
+	}
+	if want := (ChangeStats{Added: 3, Bytes: 3 + 6}); stats[0] != want {
+		t.Errorf("first change: %+v, want %+v", stats[0], want)
+	}
+	if want := (ChangeStats{Removed: 1, Updated: 1, Bytes: 3 + 7}); stats[1] != want {
+		t.Errorf("second change: %+v, want %+v", stats[1], want)
+	}
+	if want := (ChangeStats{Added: 3, Removed: 1, Updated: 1, Bytes: 19}); f.Stats() != want {
+		t.Errorf("Stats = %+v, want %+v", f.Stats(), want)
+	}
+}
+
+// closed returns a closed channel, to stop Follow once it caught up.
+func closed() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}
@@ -0,0 +1,208 @@
+package safe_treap
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CheckpointOptions configures a Checkpointer.
+type CheckpointOptions struct {
+	Dir   string // directory holding the checkpoint files
+	Codec Codec  // codec the checkpoints are encoded with (see EncodeCanonical)
+
+	// Interval is the time between two checkpoints, if > 0.
+	Interval time.Duration
+	// Mutations is the number of writes after which a checkpoint is taken,
+	// if > 0.
+	Mutations int
+	// Keep is the number of checkpoints retained; older ones are removed
+	// after every checkpoint.  Values < 1 keep one.
+	Keep int
+}
+
+const checkpointPrefix = "checkpoint-"
+
+// Checkpointer periodically writes the current root of a SafeTreap to disk,
+// every Interval and after every Mutations writes, keeping the last Keep
+// checkpoints.  A checkpoint is only taken if the root changed since the
+// previous one.
+//
+// Checkpoints are written atomically in the form read back by DecodeSorted
+// to files named checkpoint-<sequence> in Dir, so the latest one (see
+// LatestCheckpoint) is always complete.  A SafeTreap may be checkpointed by a
+// single Checkpointer at a time.
+type Checkpointer struct {
+	s    *SafeTreap
+	opts CheckpointOptions
+
+	writes atomic.Int64  // since the last checkpoint
+	kick   chan struct{} // asks for a checkpoint after opts.Mutations writes
+	stop   chan struct{}
+	done   chan struct{}
+
+	mu    sync.Mutex // serializes checkpoints
+	seq   uint64
+	last  *Node // root of the last checkpoint, if taken
+	taken bool
+	err   error
+}
+
+// NewCheckpointer creates a Checkpointer of s, numbering its checkpoints after
+// those already in opts.Dir.
+func NewCheckpointer(s *SafeTreap, opts CheckpointOptions) (*Checkpointer, error) {
+	if opts.Keep < 1 {
+		opts.Keep = 1
+	}
+	seqs, err := checkpoints(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	c := &Checkpointer{
+		s:    s,
+		opts: opts,
+		kick: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if len(seqs) > 0 {
+		c.seq = seqs[len(seqs)-1]
+	}
+	return c, nil
+}
+
+// Start starts taking checkpoints in a goroutine of its own, until Stop.
+func (c *Checkpointer) Start() {
+	onWrite := func() {
+		if c.opts.Mutations > 0 && c.writes.Add(1) >= int64(c.opts.Mutations) {
+			select {
+			case c.kick <- struct{}{}:
+			default:
+			}
+		}
+	}
+	c.s.onWrite.Store(&onWrite)
+	go c.run()
+}
+
+func (c *Checkpointer) run() {
+	defer close(c.done)
+	var tick <-chan time.Time
+	if c.opts.Interval > 0 {
+		t := time.NewTicker(c.opts.Interval)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	for {
+		select {
+		case <-tick:
+		case <-c.kick:
+		case <-c.stop:
+			return
+		}
+		c.Checkpoint()
+	}
+}
+
+// Stop stops the goroutine started by Start, takes a last checkpoint if the
+// root changed since the previous one and returns the error of the last
+// checkpoint that failed, if any.
+func (c *Checkpointer) Stop() error {
+	c.s.onWrite.Store(nil)
+	close(c.stop)
+	<-c.done
+
+	c.Checkpoint()
+	return c.Err()
+}
+
+// Err returns the error of the last checkpoint that failed, if any.
+func (c *Checkpointer) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Checkpoint takes a checkpoint now, unless the root did not change since the
+// previous one.
+func (c *Checkpointer) Checkpoint() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writes.Store(0)
+	root := c.s.Root()
+	if c.taken && root == c.last {
+		return nil
+	}
+
+	path := filepath.Join(c.opts.Dir, checkpointName(c.seq+1))
+	err := writeFileAtomic(path, func(w io.Writer) error {
+		return EncodeCanonical(w, root, c.opts.Codec)
+	})
+	if err == nil {
+		c.seq, c.last, c.taken = c.seq+1, root, true
+		err = c.prune()
+	}
+	if err != nil {
+		c.err = err
+	}
+	return err
+}
+
+// prune removes the checkpoints older than the last opts.Keep.
+func (c *Checkpointer) prune() error {
+	seqs, err := checkpoints(c.opts.Dir)
+	if err != nil {
+		return err
+	}
+	for len(seqs) > c.opts.Keep {
+		if err := os.Remove(filepath.Join(c.opts.Dir, checkpointName(seqs[0]))); err != nil {
+			return err
+		}
+		seqs = seqs[1:]
+	}
+	return nil
+}
+
+// LatestCheckpoint returns the path of the latest checkpoint written to dir by
+// a Checkpointer, or "" if there is none.
+func LatestCheckpoint(dir string) (string, error) {
+	seqs, err := checkpoints(dir)
+	if err != nil || len(seqs) == 0 {
+		return "", err
+	}
+	return filepath.Join(dir, checkpointName(seqs[len(seqs)-1])), nil
+}
+
+func checkpointName(seq uint64) string {
+	return fmt.Sprintf("%s%020d", checkpointPrefix, seq)
+}
+
+// checkpoints returns the sequence numbers of the checkpoints in dir in
+// ascending order.
+func checkpoints(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var seqs []uint64
+	for _, e := range entries {
+		name, ok := strings.CutPrefix(e.Name(), checkpointPrefix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		if seq, err := strconv.ParseUint(name, 10, 64); err == nil {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
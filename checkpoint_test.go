@@ -0,0 +1,113 @@
+package safe_treap
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// restore decodes the latest checkpoint in dir.
+func restore(t *testing.T, tr *Treap, dir string) *Node {
+	t.Helper()
+	path, err := LatestCheckpoint(dir)
+	if err != nil || path == "" {
+		t.Fatalf("LatestCheckpoint = %q, %v", path, err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	root, err := tr.DecodeSorted(f, GobCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+	}
+}
+
+func TestCheckpointerMutations(t *testing.T) {
+	tr := NewIntTreap()
+	s := NewSafeTreap(tr)
+	dir := t.TempDir()
+	c, err := NewCheckpointer(s, CheckpointOptions{Dir: dir, Codec: GobCodec, Mutations: 10, Keep: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Start()
+
+	for k := 0; k < 35; k++ {
+		s.Put(k, k)
+	}
+	waitFor(t, func() bool {
+		path, _ := LatestCheckpoint(dir)
+		return path != ""
+	})
+	if err := c.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	if root := restore(t, tr, dir); tr.Len(root) != 35 {
+		t.Errorf("the last checkpoint holds %d keys, want 35", tr.Len(root))
+	}
+	seqs, _ := checkpoints(dir)
+	if len(seqs) == 0 || len(seqs) > 2 {
+		t.Errorf("%d checkpoints retained, want at most 2", len(seqs))
+	}
+
+	// an unchanged root is not checkpointed again.
+	last := seqs[len(seqs)-1]
+	c.Checkpoint()
+	if seqs, _ := checkpoints(dir); seqs[len(seqs)-1] != last {
+		t.Error("an unchanged root was checkpointed")
+	}
+
+	// a new Checkpointer numbers its checkpoints after the existing ones.
+	s.Put(100, 100)
+	next, err := NewCheckpointer(s, CheckpointOptions{Dir: dir, Codec: GobCodec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := next.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if seqs, _ := checkpoints(dir); len(seqs) != 1 || seqs[0] != last+1 {
+		t.Errorf("checkpoints %v after %d with Keep 1", seqs, last)
+	}
+}
+
+func TestCheckpointerInterval(t *testing.T) {
+	tr := NewIntTreap()
+	s := NewSafeTreap(tr)
+	s.Put(1, 1)
+	dir := t.TempDir()
+	c, err := NewCheckpointer(s, CheckpointOptions{Dir: dir, Codec: GobCodec, Interval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Start()
+	defer c.Stop()
+
+	waitFor(t, func() bool {
+		path, _ := LatestCheckpoint(dir)
+		return path != ""
+	})
+	if root := restore(t, tr, dir); tr.Len(root) != 1 {
+		t.Errorf("the checkpoint holds %d keys, want 1", tr.Len(root))
+	}
+}
+
+func TestCheckpointerMissingDir(t *testing.T) {
+	if _, err := NewCheckpointer(NewSafeTreap(NewIntTreap()), CheckpointOptions{Dir: t.TempDir() + "/missing"}); err == nil {
+		t.Error("NewCheckpointer accepted a missing directory")
+	}
+}
@@ -0,0 +1,136 @@
+package safe_treap
+
+import (
+	"encoding/gob"
+	"errors"
+	"hash/fnv"
+	"io"
+)
+
+// ErrUnsorted is returned when a stream that must be in ascending key order
+// is not.
+var ErrUnsorted = errors.New("records are not in ascending key order")
+
+// Record is the serialized form of a single treap entry.
+type Record struct {
+	Key, Item interface{}
+	Weight    int
+}
+
+// Codec converts treap entries to and from a stream of records.
+type Codec interface {
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+// Encoder writes records to a stream.
+type Encoder interface {
+	Encode(rec *Record) error
+}
+
+// Decoder reads records from a stream.  Decode returns io.EOF once the stream
+// is exhausted.
+type Decoder interface {
+	Decode(rec *Record) error
+}
+
+// GobCodec encodes records with encoding/gob.  Concrete key and item types
+// other than the builtin ones must be registered with gob.Register.
+var GobCodec Codec = gobCodec{}
+
+type gobCodec struct{}
+
+func (gobCodec) NewEncoder(w io.Writer) Encoder { return gobEncoder{gob.NewEncoder(w)} }
+func (gobCodec) NewDecoder(r io.Reader) Decoder { return gobDecoder{gob.NewDecoder(r)} }
+
+type gobEncoder struct{ enc *gob.Encoder }
+type gobDecoder struct{ dec *gob.Decoder }
+
+func (e gobEncoder) Encode(rec *Record) error { return e.enc.Encode(rec) }
+func (d gobDecoder) Decode(rec *Record) error { return d.dec.Decode(rec) }
+
+// DecodeSorted builds a treap from a stream of records that is already in
+// ascending key order, keeping the weight of every record.
+//
+// The treap is assembled in O(n) while the stream is read, so only the nodes
+// themselves are held in memory.  ErrUnsorted is returned if a key is not
+// greater than the one before it.
+func (t *Treap) DecodeSorted(r io.Reader, codec Codec) (*Node, error) {
+	var (
+		b    = newBuilder(t.h())
+		dec  = codec.NewDecoder(r)
+		last *Node
+	)
+
+	for {
+		var rec Record
+		if err := dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if last != nil && t.h().CompareKeys(rec.Key, last.Key) <= 0 {
+			return nil, ErrUnsorted
+		}
+
+		last = t.newNode()
+		last.Weight, last.Key, last.Item = rec.Weight, rec.Key, rec.Item
+		b.push(last)
+	}
+
+	return b.root(), nil
+}
+
+// EncodeCanonical writes the entries of n to w in key order, the form read
+// back by DecodeSorted.
+//
+// The output depends only on the entries and their weights, never on the
+// order of the operations that built the treap, so with weights derived from
+// the keys (see HashWeight) and a deterministic codec, equal treaps encode to
+// identical bytes that can be signed or compared across machines.  Note that
+// encoding/gob is deterministic except for values containing maps.
+func EncodeCanonical(w io.Writer, n *Node, codec Codec) error {
+	return encodeSorted(codec.NewEncoder(w), n)
+}
+
+// HashWeight derives a weight from the encoded form of a key with 64-bit
+// FNV-1a.  Hash-derived weights are as well distributed as random ones, but
+// make the shape of a treap a function of its keys alone.
+func HashWeight(key []byte) int {
+	h := fnv.New64a()
+	h.Write(key)
+	return int(h.Sum64() >> 1)
+}
+
+// ExportProjection wraps codec so that the item of every encoded record is
+// replaced by fields(item), e.g. to leave bulky blobs or sensitive fields out
+// of snapshots without transforming the live treap.  Decoding is unchanged
+// and yields the projected items.
+func ExportProjection(codec Codec, fields func(val interface{}) interface{}) Codec {
+	return projection{codec: codec, fields: fields}
+}
+
+type projection struct {
+	codec  Codec
+	fields func(interface{}) interface{}
+}
+
+func (p projection) NewEncoder(w io.Writer) Encoder {
+	return projectionEncoder{enc: p.codec.NewEncoder(w), fields: p.fields}
+}
+
+func (p projection) NewDecoder(r io.Reader) Decoder {
+	return p.codec.NewDecoder(r)
+}
+
+type projectionEncoder struct {
+	enc    Encoder
+	fields func(interface{}) interface{}
+}
+
+func (e projectionEncoder) Encode(rec *Record) error {
+	projected := *rec
+	projected.Item = e.fields(rec.Item)
+	return e.enc.Encode(&projected)
+}
@@ -0,0 +1,51 @@
+// Package collate provides locale-aware string comparators for safe_treap.
+// It lives in a module of its own so that the treap module does not depend
+// on golang.org/x/text.
+package collate
+
+import (
+	"sync"
+
+	treap "github.com/fearblackcat/safe-treap"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// Comparator orders strings according to the collation rules of the given
+// language, e.g. collate.IgnoreCase or collate.IgnoreDiacritics make keys
+// that differ only in case or accents compare equal.
+// Nil values are treated as infinite.
+//
+// A collate.Collator is not safe for concurrent use, so the comparator keeps
+// a pool of them and may be shared by concurrent readers like the builtin
+// comparators.
+func Comparator(tag language.Tag, opts ...collate.Option) treap.Comparator {
+	pool := sync.Pool{
+		New: func() interface{} {
+			return collate.New(tag, opts...)
+		},
+	}
+
+	return func(a, b interface{}) int {
+		switch {
+		case a == nil:
+			return -1 // N.B.:  treap is a min-heap by default
+		case b == nil:
+			return 1
+		}
+
+		c := pool.Get().(*collate.Collator)
+		defer pool.Put(c)
+
+		return c.CompareString(a.(string), b.(string))
+	}
+}
+
+// NewHandle returns a Handle for string keys sorted per the locale tag (see
+// Comparator), with weights ordered by weights.
+func NewHandle(tag language.Tag, weights treap.Comparator, opts ...collate.Option) *treap.Handle {
+	return &treap.Handle{
+		CompareKeys:    Comparator(tag, opts...),
+		CompareWeights: weights,
+	}
+}
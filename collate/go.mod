@@ -0,0 +1,7 @@
+module github.com/fearblackcat/safe-treap/collate
+
+go 1.23
+
+// The treap module is taken from this repository by the go.work file next to
+// this one.  Release builds require its tagged version instead.
+require golang.org/x/text v0.3.8
@@ -1,6 +1,7 @@
 package safe_treap
 
 import (
+	"fmt"
 	"time"
 	"unsafe"
 )
@@ -10,6 +11,77 @@ import (
 // Nil values are treated as -Inf.
 type Comparator func(a, b interface{}) int
 
+// MinHeap returns the int weight comparator that keeps the lowest weight at
+// the root, which is the default.
+func MinHeap() Comparator {
+	return IntComparator
+}
+
+// MaxHeap returns the int weight comparator that keeps the highest weight at
+// the root, so that higher weights behave as higher priorities.
+func MaxHeap() Comparator {
+	return Reverse(IntComparator)
+}
+
+// Reverse returns the comparator ordering values the other way round from c,
+// e.g. to keep keys in descending order.  Nil values, the least under c, are
+// the greatest under Reverse(c).
+func Reverse(c Comparator) Comparator {
+	return func(a, b interface{}) int {
+		return c(b, a)
+	}
+}
+
+// OrderedComparator compares two values of the same builtin ordered type
+// (integers, floats, strings, []byte and time.Time) by dispatching to the
+// comparator for that type.  It panics, naming the type, for any other type:
+// such keys need a Handle with a comparator of their own.
+// Nil values are treated as infinite.
+func OrderedComparator(a, b interface{}) int {
+	switch {
+	case a == nil:
+		return -1 // N.B.:  treap is a min-heap by default
+	case b == nil:
+		return 1
+	}
+
+	switch a.(type) {
+	case int:
+		return IntComparator(a, b)
+	case int8:
+		return Int8Comparator(a, b)
+	case int16:
+		return Int16Comparator(a, b)
+	case int32:
+		return Int32Comparator(a, b)
+	case int64:
+		return Int64Comparator(a, b)
+	case uint:
+		return UIntComparator(a, b)
+	case uint8:
+		return UInt8Comparator(a, b)
+	case uint16:
+		return UInt16Comparator(a, b)
+	case uint32:
+		return UInt32Comparator(a, b)
+	case uint64:
+		return UInt64Comparator(a, b)
+	case float32:
+		return Float32Comparator(a, b)
+	case float64:
+		return Float64Comparator(a, b)
+	case string:
+		return StringComparator(a, b)
+	case []byte:
+		return BytesComparator(a, b)
+	case time.Time:
+		return TimeComparator(a, b)
+	default:
+		panic(fmt.Sprintf("safe_treap: no default ordering for keys of type %T, "+
+			"create the treap with NewTreap and a Handle comparing them", a))
+	}
+}
+
 // IntComparator compares integers.  Nil values are considered infinite.
 func IntComparator(a, b interface{}) int {
 	switch {
@@ -0,0 +1,27 @@
+package safe_treap
+
+// DeleteIf removes every entry of n for which pred returns true, returning the
+// new root and the number of entries removed.
+//
+// pred is called once per entry, in key order.  Subtrees without any removed
+// entry are shared with n, and the remaining entries keep their weights, so
+// the result has the same shape as if the matching keys had been deleted one
+// by one.  O(n).
+func (t *Treap) DeleteIf(n *Node, pred func(key, val interface{}) bool) (*Node, int) {
+	if n == nil {
+		return nil, 0
+	}
+
+	left, dl := t.DeleteIf(n.Left, pred)
+	drop := pred(n.Key, n.Item)
+	right, dr := t.DeleteIf(n.Right, pred)
+
+	switch {
+	case drop:
+		return t.join(left, right), dl + dr + 1
+	case dl == 0 && dr == 0:
+		return n, 0
+	default:
+		return t.clone(n, left, right), dl + dr
+	}
+}
@@ -0,0 +1,67 @@
+package safe_treap
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDeleteIf(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 200)
+	even := func(key, val interface{}) bool { return key.(int)%2 == 0 }
+
+	var visited []interface{}
+	res, removed := tr.DeleteIf(root, func(key, val interface{}) bool {
+		visited = append(visited, key)
+		return even(key, val)
+	})
+	if err := tr.Validate(res); err != nil {
+		t.Fatal(err)
+	}
+	if removed != 100 || tr.Len(res) != 100 {
+		t.Errorf("removed %d keys, Len = %d", removed, tr.Len(res))
+	}
+	if len(visited) != 200 || visited[0] != 0 || visited[199] != 199 {
+		t.Errorf("pred was not called once per key in order")
+	}
+
+	// the same shape as deleting the keys one by one.
+	want := root
+	for k := 0; k < 200; k += 2 {
+		want, _ = tr.Delete(want, k)
+	}
+	if got, exp := fmt.Sprint(preorder(tr, res)), fmt.Sprint(preorder(tr, want)); got != exp {
+		t.Errorf("DeleteIf built another shape than Delete:\n%s\n%s", got, exp)
+	}
+	if tr.Len(root) != 200 {
+		t.Error("DeleteIf modified its input")
+	}
+}
+
+func TestDeleteIfSharing(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 100)
+	if res, removed := tr.DeleteIf(root, func(key, val interface{}) bool { return false }); res != root || removed != 0 {
+		t.Error("removing nothing copied the treap")
+	}
+
+	res, _ := tr.DeleteIf(root, func(key, val interface{}) bool { return key == 50 })
+	shared := 0
+	ascend(res, func(n *Node) bool {
+		if orig, _ := tr.GetNode(root, n.Key); orig == n {
+			shared++
+		}
+		return true
+	})
+	// only the ancestors of 50 and the spines joined in its place are copied:
+	// both search paths of 50 run along them.
+	lo, _ := tr.lowerBoundPath(root, 50)
+	hi, _ := tr.upperBoundPath(root, 50)
+	if shared < 99-lo-hi {
+		t.Errorf("only %d of 99 nodes are shared", shared)
+	}
+
+	if res, removed := tr.DeleteIf(root, func(key, val interface{}) bool { return true }); res != nil || removed != 100 {
+		t.Errorf("removing every key left %d", tr.Len(res))
+	}
+}
@@ -0,0 +1,139 @@
+package safe_treap
+
+import "errors"
+
+// ErrDepthExceeded is returned by the checked operations when a descent visits
+// more nodes than allowed by WithMaxDepth, or when an insertion would add a
+// leaf deeper than that.
+var ErrDepthExceeded = errors.New("treap depth limit exceeded")
+
+// searchDepth follows the search path for key from n, returning the node
+// holding key (or nil) and ErrDepthExceeded if the path is longer than the
+// depth limit of t.  If insert is set, a missing key counts the leaf it would
+// be inserted as.
+func (t *Treap) searchDepth(n *Node, key interface{}, insert bool) (*Node, error) {
+	depth := 0
+	for n != nil {
+		if depth++; t.maxDepth > 0 && depth > t.maxDepth {
+			return nil, ErrDepthExceeded
+		}
+
+		switch comp := t.h().CompareKeys(key, n.Key); {
+		case comp < 0:
+			n = n.Left
+		case comp > 0:
+			n = n.Right
+		default:
+			return n, nil
+		}
+	}
+	if insert && t.maxDepth > 0 && depth+1 > t.maxDepth {
+		return nil, ErrDepthExceeded
+	}
+	return nil, nil
+}
+
+// GetChecked is like Get, but fails with ErrDepthExceeded if the lookup has to
+// descend deeper than the limit set with WithMaxDepth.
+func (t *Treap) GetChecked(n *Node, key interface{}) (v interface{}, found bool, err error) {
+	if n, err = t.searchDepth(n, key, false); n != nil {
+		v, found = t.value(n.Item), true
+	}
+	return
+}
+
+// InsertChecked is like Insert, but fails with ErrDepthExceeded, leaving n
+// untouched, if the insertion has to descend deeper than the limit set with
+// WithMaxDepth, and with a *SizeError if key or val exceed the limits set with
+// WithMaxKeySize and WithMaxValueSize.
+func (t *Treap) InsertChecked(n *Node, key, val interface{}, weight int) (*Node, bool, error) {
+	if err := t.checkSizes(key, val); err != nil {
+		return n, false, err
+	}
+
+	found, err := t.searchDepth(n, key, true)
+	if err != nil {
+		return n, false, err
+	}
+	if found != nil {
+		return n, false, nil
+	}
+
+	n, ok := t.upsert(n, key, val, weight, true, false, nil)
+	return n, ok, nil
+}
+
+// UpsertChecked is like Upsert, but fails like InsertChecked, leaving n
+// untouched, if key or val exceed the size limits of the treap or if writing
+// key has to descend deeper than the depth limit.
+func (t *Treap) UpsertChecked(n *Node, key, val interface{}, weight int) (*Node, bool, error) {
+	if err := t.checkWrite(n, key, val); err != nil {
+		return n, false, err
+	}
+	n, created := t.Upsert(n, key, val, weight)
+	return n, created, nil
+}
+
+// PutChecked is like Put, but fails like UpsertChecked.
+func (t *Treap) PutChecked(n *Node, key, val interface{}) (*Node, bool, error) {
+	if err := t.checkWrite(n, key, val); err != nil {
+		return n, false, err
+	}
+	n, created := t.Put(n, key, val)
+	return n, created, nil
+}
+
+// DeleteChecked is like Delete, but fails with ErrDepthExceeded, leaving n
+// untouched, if the key is deeper than the limit set with WithMaxDepth.
+func (t *Treap) DeleteChecked(n *Node, key interface{}) (*Node, bool, error) {
+	if err := t.checkDepth(n, key, false); err != nil {
+		return n, false, err
+	}
+	n, ok := t.Delete(n, key)
+	return n, ok, nil
+}
+
+// checkWrite enforces the size limits of t on key and val and its depth limit
+// on a write of key to n.
+func (t *Treap) checkWrite(n *Node, key, val interface{}) error {
+	if err := t.checkSizes(key, val); err != nil {
+		return err
+	}
+	return t.checkDepth(n, key, true)
+}
+
+// checkDepth enforces the depth limit of t on the search path for key from n.
+// insert is as for searchDepth.
+func (t *Treap) checkDepth(n *Node, key interface{}, insert bool) error {
+	if t.maxDepth <= 0 {
+		return nil
+	}
+	_, err := t.searchDepth(n, key, insert)
+	return err
+}
+
+// PathStep is a node visited while descending the treap.
+type PathStep struct {
+	Key    interface{}
+	Weight int
+}
+
+// GetPath returns the nodes visited, from the root down, while looking up key,
+// and whether key was found (in which case it is the last step).  The length
+// of the path is the cost of the lookup, which makes it a direct way to check
+// the balance of a live treap.
+func (t *Treap) GetPath(n *Node, key interface{}) (path []PathStep, found bool) {
+	for n != nil {
+		path = append(path, PathStep{Key: n.Key, Weight: n.Weight})
+
+		switch comp := t.h().CompareKeys(key, n.Key); {
+		case comp < 0:
+			n = n.Left
+		case comp > 0:
+			n = n.Right
+		default:
+			return path, true
+		}
+	}
+	return path, false
+}
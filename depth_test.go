@@ -0,0 +1,92 @@
+package safe_treap
+
+import (
+	"errors"
+	"testing"
+)
+
+// chain builds a treap of the keys 0..n-1 degraded into a right spine, with
+// a depth limit of max.
+func chain(t *testing.T, n, max int) (*Treap, *Node) {
+	t.Helper()
+	tr, err := NewTreap(&Handle{CompareKeys: IntComparator, CompareWeights: IntComparator},
+		WithMaxDepth(max), WithMisusePolicy(PanicOnMisuse))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var root *Node
+	for k := 0; k < n; k++ {
+		root, _ = tr.Insert(root, k, k, k)
+	}
+	return tr, root
+}
+
+func TestDepthLimit(t *testing.T) {
+	tr, root := chain(t, 20, 10)
+	if tr.Len(root) != 20 {
+		t.Fatalf("the unchecked inserts stopped at %d keys", tr.Len(root))
+	}
+
+	if v, ok, err := tr.GetChecked(root, 9); v != 9 || !ok || err != nil {
+		t.Errorf("GetChecked(9) = %v, %v, %v", v, ok, err)
+	}
+	if _, _, err := tr.GetChecked(root, 10); err != ErrDepthExceeded {
+		t.Errorf("GetChecked(10): %v", err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		write func() (*Node, bool, error)
+	}{
+		{"InsertChecked", func() (*Node, bool, error) { return tr.InsertChecked(root, 100, 0, 100) }},
+		{"UpsertChecked", func() (*Node, bool, error) { return tr.UpsertChecked(root, 15, 0, 15) }},
+		{"PutChecked", func() (*Node, bool, error) { return tr.PutChecked(root, 15, 0) }},
+		{"DeleteChecked", func() (*Node, bool, error) { return tr.DeleteChecked(root, 15) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, ok, err := tc.write()
+			if err != ErrDepthExceeded || ok || res != root {
+				t.Errorf("= %v, %v, want ErrDepthExceeded and no change", ok, err)
+			}
+		})
+	}
+
+	// an insertion counts the leaf it adds.
+	tr, root = chain(t, 9, 10)
+	if _, _, err := tr.InsertChecked(root, 9, 9, 9); err != nil {
+		t.Errorf("InsertChecked of a leaf at the limit: %v", err)
+	}
+	tr, root = chain(t, 9, 9)
+	if _, _, err := tr.InsertChecked(root, 9, 9, 9); err != ErrDepthExceeded {
+		t.Errorf("InsertChecked of a leaf past the limit: %v", err)
+	}
+}
+
+func TestDepthLimitCallers(t *testing.T) {
+	tr, root := chain(t, 20, 10)
+
+	if res, err := tr.Apply(root, []Op{{Kind: OpPut, Key: 0, Value: "zero"}, {Kind: OpDelete, Key: 15}}); !errors.Is(err, ErrDepthExceeded) || res != root {
+		t.Errorf("Apply past the depth limit: %v", err)
+	}
+
+	s := NewSafeTreap(tr)
+	var err error
+	for k := 0; k < 20 && err == nil; k++ {
+		_, err = s.Upsert(k, k, k)
+	}
+	if err != ErrDepthExceeded || s.Len() != 10 {
+		t.Errorf("SafeTreap.Upsert stopped with %v at %d keys", err, s.Len())
+	}
+
+	m := NewMemtable(tr, GobCodec, nil)
+	m.root.Store(root)
+	if err := m.Put(15, "new"); err != ErrDepthExceeded {
+		t.Errorf("Memtable.Put past the depth limit: %v", err)
+	}
+	if err := m.Delete(15); err != ErrDepthExceeded {
+		t.Errorf("Memtable.Delete past the depth limit: %v", err)
+	}
+	if v, _ := m.Get(15); v != 15 {
+		t.Errorf("Memtable.Get(15) = %v after rejected writes", v)
+	}
+}
@@ -0,0 +1,98 @@
+package safe_treap
+
+// Deque is a persistent double-ended queue: an implicit treap, whose nodes
+// are ordered by position rather than by key, so both ends and any position
+// are reached in O(log n) expected time through the subtree sizes.  Every
+// operation returns a new Deque and leaves the receiver untouched, which
+// makes a queue snapshot-able for free, e.g. to replay a work queue from an
+// earlier state.  The nil *Deque is empty.
+type Deque struct {
+	root *Node
+}
+
+// implicit builds the nodes of deques.  Keys are left nil and never compared.
+var implicit Treap
+
+// Len returns the number of items.
+func (d *Deque) Len() int {
+	if d == nil {
+		return 0
+	}
+	return nodeSize(d.root)
+}
+
+// At returns the item at position i, counting from 0 at the front, and false
+// if i is out of range.
+func (d *Deque) At(i int) (interface{}, bool) {
+	if d == nil {
+		return nil, false
+	}
+	if n := selectNode(d.root, i); n != nil {
+		return n.Item, true
+	}
+	return nil, false
+}
+
+// PushFront returns the deque with val added at the front.
+func (d *Deque) PushFront(val interface{}) *Deque {
+	return &Deque{root: implicit.join(leaf(val), d.rootOrNil())}
+}
+
+// PushBack returns the deque with val added at the back.
+func (d *Deque) PushBack(val interface{}) *Deque {
+	return &Deque{root: implicit.join(d.rootOrNil(), leaf(val))}
+}
+
+// PopFront returns the item at the front and the deque without it, or false
+// if the deque is empty.
+func (d *Deque) PopFront() (interface{}, *Deque, bool) {
+	if d.Len() == 0 {
+		return nil, d, false
+	}
+	front, rest := splitAt(d.root, 1)
+	return front.Item, &Deque{root: rest}, true
+}
+
+// PopBack returns the item at the back and the deque without it, or false if
+// the deque is empty.
+func (d *Deque) PopBack() (interface{}, *Deque, bool) {
+	if d.Len() == 0 {
+		return nil, d, false
+	}
+	rest, back := splitAt(d.root, d.Len()-1)
+	return back.Item, &Deque{root: rest}, true
+}
+
+// Ascend visits the items from front to back until fn returns false.
+func (d *Deque) Ascend(fn func(val interface{}) bool) {
+	ascend(d.rootOrNil(), func(n *Node) bool {
+		return fn(n.Item)
+	})
+}
+
+func (d *Deque) rootOrNil() *Node {
+	if d == nil {
+		return nil
+	}
+	return d.root
+}
+
+func leaf(val interface{}) *Node {
+	n := implicit.newNode()
+	n.Weight, n.Item, n.size = implicit.randomWeight(), val, 1
+	return n
+}
+
+// splitAt partitions n into its first k nodes and the others, copying only
+// the path to the split point.
+func splitAt(n *Node, k int) (left, right *Node) {
+	if n == nil {
+		return nil, nil
+	}
+	if size := nodeSize(n.Left); k > size {
+		left, right = splitAt(n.Right, k-size-1)
+		return implicit.clone(n, n.Left, left), right
+	}
+	left, right = splitAt(n.Left, k)
+	return left, implicit.clone(n, right, n.Right)
+}
@@ -0,0 +1,82 @@
+package safe_treap
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestDeque(t *testing.T) {
+	var d *Deque
+	if _, _, ok := d.PopFront(); ok || d.Len() != 0 {
+		t.Error("the nil deque is not empty")
+	}
+
+	d = d.PushBack(1).PushBack(2).PushFront(0)
+	for i := 0; i < 3; i++ {
+		if v, ok := d.At(i); !ok || v != i {
+			t.Errorf("At(%d) = %v, %v", i, v, ok)
+		}
+	}
+	if _, ok := d.At(3); ok {
+		t.Error("At past the back succeeded")
+	}
+
+	front, rest, _ := d.PopFront()
+	back, rest, _ := rest.PopBack()
+	if front != 0 || back != 2 || rest.Len() != 1 {
+		t.Errorf("popped %v and %v, leaving %d items", front, back, rest.Len())
+	}
+	if d.Len() != 3 {
+		t.Error("popping modified the older deque")
+	}
+}
+
+func TestDequeModel(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var d *Deque
+	var model []int
+	var versions []*Deque
+	var models [][]int
+
+	for i := 0; i < 5000; i++ {
+		switch rnd.Intn(4) {
+		case 0:
+			d, model = d.PushFront(i), append([]int{i}, model...)
+		case 1:
+			d, model = d.PushBack(i), append(model, i)
+		case 2:
+			v, res, ok := d.PopFront()
+			if ok != (len(model) > 0) || ok && v != model[0] {
+				t.Fatalf("PopFront = %v, %v", v, ok)
+			}
+			if ok {
+				d, model = res, model[1:]
+			}
+		default:
+			v, res, ok := d.PopBack()
+			if ok != (len(model) > 0) || ok && v != model[len(model)-1] {
+				t.Fatalf("PopBack = %v, %v", v, ok)
+			}
+			if ok {
+				d, model = res, model[:len(model)-1]
+			}
+		}
+		if i%250 == 0 {
+			versions, models = append(versions, d), append(models, append([]int(nil), model...))
+		}
+	}
+
+	for i, v := range versions {
+		if v.Len() != len(models[i]) {
+			t.Fatalf("version %d holds %d items, want %d", i, v.Len(), len(models[i]))
+		}
+		pos := 0
+		v.Ascend(func(val interface{}) bool {
+			if at, _ := v.At(pos); val != models[i][pos] || at != val {
+				t.Fatalf("version %d: item %d is %v, At says %v, want %d", i, pos, val, at, models[i][pos])
+			}
+			pos++
+			return true
+		})
+	}
+}
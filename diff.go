@@ -0,0 +1,82 @@
+package safe_treap
+
+import "reflect"
+
+// Diff returns the entries added, removed and changed going from the version
+// old to the version new of a treap, each in key order.  Changed entries hold
+// the new item; an entry only changed when its item does, as compared by
+// WithEqualValues if set, else with == (reflect.DeepEqual for items that are
+// not comparable).
+//
+// Subtrees shared by the two versions are skipped without being visited, so
+// diffing a version against one derived from it by k updates costs about
+// O(k log n) instead of a walk of both treaps.
+func (t *Treap) Diff(old, new *Node) (added, removed, changed []KV) {
+	d := differ{t: t}
+	d.diff(old, new)
+	return d.added, d.removed, d.changed
+}
+
+type differ struct {
+	t                       *Treap
+	added, removed, changed []KV
+}
+
+func (d *differ) diff(a, b *Node) {
+	t := d.t
+	switch {
+	case a == b:
+		return
+	case a == nil:
+		ascend(b, func(n *Node) bool {
+			d.added = append(d.added, KV{Key: n.Key, Value: t.value(n.Item)})
+			return true
+		})
+		return
+	case b == nil:
+		ascend(a, func(n *Node) bool {
+			d.removed = append(d.removed, KV{Key: n.Key, Value: t.value(n.Item)})
+			return true
+		})
+		return
+	}
+
+	// split the version whose root ranks second by the key of the other root,
+	// which leaves the subtrees off the split path shared with the original.
+	if t.h().CompareWeights(a.Weight, b.Weight) <= 0 {
+		l, found, r := t.split(b, a.Key)
+		d.diff(a.Left, l)
+		switch {
+		case found == nil:
+			d.removed = append(d.removed, KV{Key: a.Key, Value: t.value(a.Item)})
+		case !t.sameItem(a.Item, found.Item):
+			d.changed = append(d.changed, KV{Key: found.Key, Value: t.value(found.Item)})
+		}
+		d.diff(a.Right, r)
+		return
+	}
+
+	l, found, r := t.split(a, b.Key)
+	d.diff(l, b.Left)
+	switch {
+	case found == nil:
+		d.added = append(d.added, KV{Key: b.Key, Value: t.value(b.Item)})
+	case !t.sameItem(found.Item, b.Item):
+		d.changed = append(d.changed, KV{Key: b.Key, Value: t.value(b.Item)})
+	}
+	d.diff(r, b.Right)
+}
+
+// sameItem reports whether the items a and b are equal for Diff.
+func (t *Treap) sameItem(a, b interface{}) bool {
+	switch {
+	case t.equal != nil:
+		return t.equal(a, b)
+	case a == nil || b == nil:
+		return a == b
+	case reflect.TypeOf(a).Comparable() && reflect.TypeOf(b).Comparable():
+		return a == b
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
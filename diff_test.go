@@ -0,0 +1,52 @@
+package safe_treap
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	tr := NewIntTreap()
+	old := fill(t, tr, 500)
+	nu := old
+	nu, _ = tr.Put(nu, 600, "added")
+	nu, _ = tr.Put(nu, 700, "added")
+	nu, _ = tr.Delete(nu, 3)
+	nu, _ = tr.Put(nu, 250, "changed")
+	nu, _ = tr.Put(nu, 100, 1000) // unchanged item
+
+	added, removed, changed := tr.Diff(old, nu)
+	if len(added) != 2 || added[0].Key != 600 || added[1].Key != 700 || added[0].Value != "added" {
+		t.Errorf("added = %v", added)
+	}
+	if len(removed) != 1 || removed[0] != (KV{3, 30}) {
+		t.Errorf("removed = %v", removed)
+	}
+	if len(changed) != 1 || changed[0] != (KV{250, "changed"}) {
+		t.Errorf("changed = %v", changed)
+	}
+
+	if a, r, c := tr.Diff(old, old); len(a)+len(r)+len(c) != 0 {
+		t.Errorf("a version differs from itself: +%v -%v ~%v", a, r, c)
+	}
+	if a, r, _ := tr.Diff(nil, old); len(a) != 500 || len(r) != 0 || a[0].Key != 0 || a[499].Key != 499 {
+		t.Errorf("Diff from an empty treap added %d keys", len(a))
+	}
+}
+
+func TestDiffItems(t *testing.T) {
+	tr := NewIntTreap()
+	old, _ := tr.Put(nil, 1, []int{1, 2})
+	same, _ := tr.Put(old, 1, []int{1, 2})
+	if _, _, c := tr.Diff(old, same); len(c) != 0 {
+		t.Errorf("equal slices reported as changed: %v", c)
+	}
+
+	loose, err := NewTreap(&Handle{CompareKeys: IntComparator, CompareWeights: IntComparator},
+		WithEqualValues(func(a, b interface{}) bool { return len(a.(string)) == len(b.(string)) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := loose.Put(nil, 1, "abc")
+	b, _ := loose.Upsert(a, 1, "xyz", a.Weight+1)
+	if _, _, c := loose.Diff(a, b); len(c) != 0 {
+		t.Errorf("items equal by WithEqualValues reported as changed: %v", c)
+	}
+}
@@ -0,0 +1,54 @@
+package safe_treap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// WriteDOT writes the treaps roots as a Graphviz DOT graph, labelling every
+// node with its key and weight, e.g. for `dot -Tsvg`.  Left children hang to
+// the south-west of their parent and right children to the south-east.
+//
+// Nodes are identified by address, so a subtree shared by several of the
+// roots is drawn once, with an edge from each version reaching it: passing
+// the versions of a treap shows how much of it they share.
+func (t *Treap) WriteDOT(w io.Writer, roots ...*Node) error {
+	bw := bufio.NewWriter(w)
+	ids := make(map[*Node]int)
+
+	var walk func(n *Node) int
+	walk = func(n *Node) int {
+		if id, ok := ids[n]; ok {
+			return id
+		}
+		id := len(ids)
+		ids[n] = id
+		fmt.Fprintf(bw, "\tn%d [label=%s];\n", id, strconv.Quote(fmt.Sprintf("%v\nw=%d", n.Key, n.Weight)))
+		if n.Left != nil {
+			fmt.Fprintf(bw, "\tn%d:sw -> n%d;\n", id, walk(n.Left))
+		}
+		if n.Right != nil {
+			fmt.Fprintf(bw, "\tn%d:se -> n%d;\n", id, walk(n.Right))
+		}
+		return id
+	}
+
+	fmt.Fprintln(bw, "digraph treap {")
+	fmt.Fprintln(bw, "\tnode [shape=box];")
+	for i, root := range roots {
+		if len(roots) > 1 {
+			fmt.Fprintf(bw, "\tv%d [label=\"version %d\", shape=plaintext];\n", i, i)
+		}
+		if root == nil {
+			continue
+		}
+		id := walk(root)
+		if len(roots) > 1 {
+			fmt.Fprintf(bw, "\tv%d -> n%d [style=dashed];\n", i, id)
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
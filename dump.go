@@ -0,0 +1,48 @@
+package safe_treap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Dump writes an indented ASCII rendering of the treap rooted at n, one node
+// per line with its key, weight and depth, for debugging without Graphviz:
+//
+//	1 w=12 d=0
+//	|-- L 0 w=40 d=1
+//	`-- R 3 w=17 d=1
+//	    `-- L 2 w=95 d=2
+//
+// An empty treap is written as "(empty)".
+func (n *Node) Dump(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if n == nil {
+		fmt.Fprintln(bw, "(empty)")
+		return bw.Flush()
+	}
+
+	var dump func(n *Node, prefix, branch string, depth int)
+	dump = func(n *Node, prefix, branch string, depth int) {
+		fmt.Fprintf(bw, "%s%v w=%d d=%d\n", branch, n.Key, n.Weight, depth)
+		switch {
+		case n.Left != nil && n.Right != nil:
+			dump(n.Left, prefix+"|   ", prefix+"|-- L ", depth+1)
+			dump(n.Right, prefix+"    ", prefix+"`-- R ", depth+1)
+		case n.Left != nil:
+			dump(n.Left, prefix+"    ", prefix+"`-- L ", depth+1)
+		case n.Right != nil:
+			dump(n.Right, prefix+"    ", prefix+"`-- R ", depth+1)
+		}
+	}
+	dump(n, "", "", 0)
+	return bw.Flush()
+}
+
+// String returns the rendering of Dump.
+func (n *Node) String() string {
+	var b strings.Builder
+	n.Dump(&b)
+	return b.String()
+}
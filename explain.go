@@ -0,0 +1,97 @@
+package safe_treap
+
+import "math"
+
+// QueryKind is the kind of operation described by a Query.
+type QueryKind int
+
+const (
+	// QueryRange scans the keys in [Lo, Hi], as Range or AppendRange do.
+	QueryRange QueryKind = iota
+	// QueryDeleteRange removes the keys in [Lo, Hi] by splitting the treap
+	// at both bounds and joining the outer parts.
+	QueryDeleteRange
+	// QueryUnion unions the treap with Other, as Union or Merge do.
+	QueryUnion
+)
+
+// Query is an operation to be explained by Explain.
+type Query struct {
+	Kind   QueryKind
+	Lo, Hi interface{} // bounds of QueryRange and QueryDeleteRange; may be Unbounded
+	Other  *Node       // second operand of QueryUnion
+}
+
+// Plan is the estimated cost of a Query.
+type Plan struct {
+	// Keys is the number of keys the operation returns or removes, or the
+	// size of the result of a union.  It is exact except for unions, which
+	// count the keys present in both treaps twice.
+	Keys int
+	// Touched is the number of nodes the operation visits.
+	Touched int
+	// Copied is the number of nodes it path copies.
+	Copied int
+	// Splits is the number of splits it performs.
+	Splits int
+}
+
+// Explain estimates how q would execute on n without running it, from the
+// subtree sizes and the search paths of the bounds, to reason about the cost
+// of big operations before committing to them.  Ranges are planned exactly
+// in O(log n); a union, whose cost depends on how the keys of both treaps
+// interleave, is estimated from their sizes as O(m log(n/m)) for m <= n.
+func (t *Treap) Explain(n *Node, q Query) Plan {
+	switch q.Kind {
+	case QueryRange:
+		lo, loRank := t.lowerBoundPath(n, q.Lo)
+		hi, hiRank := t.upperBoundPath(n, q.Hi)
+		keys := max(hiRank-loRank, 0)
+		return Plan{Keys: keys, Touched: lo + hi + keys}
+	case QueryDeleteRange:
+		lo, loRank := t.lowerBoundPath(n, q.Lo)
+		hi, hiRank := t.upperBoundPath(n, q.Hi)
+		return Plan{Keys: max(hiRank-loRank, 0), Touched: lo + hi, Copied: lo + hi, Splits: 2}
+	case QueryUnion:
+		m, k := nodeSize(n), nodeSize(q.Other)
+		if m > k {
+			m, k = k, m
+		}
+		if m == 0 {
+			return Plan{Keys: k}
+		}
+		// every node of the smaller treap splits the other one along a
+		// path of about log(k/m) nodes.
+		cost := m * int(math.Ceil(math.Log2(float64(k)/float64(m)+1)))
+		return Plan{Keys: m + k, Touched: cost, Copied: cost, Splits: m}
+	}
+	return Plan{}
+}
+
+// lowerBoundPath returns the length of the search path for the lower bound lo
+// in n and the number of keys below lo.
+func (t *Treap) lowerBoundPath(n *Node, lo interface{}) (depth, rank int) {
+	for ; n != nil; depth++ {
+		if lo != Unbounded && t.h().CompareKeys(lo, n.Key) > 0 {
+			rank += nodeSize(n.Left) + 1
+			n = n.Right
+		} else {
+			n = n.Left
+		}
+	}
+	return depth, rank
+}
+
+// upperBoundPath returns the length of the search path for the upper bound hi
+// in n and the number of keys up to hi.
+func (t *Treap) upperBoundPath(n *Node, hi interface{}) (depth, rank int) {
+	for ; n != nil; depth++ {
+		if hi == Unbounded || t.h().CompareKeys(hi, n.Key) >= 0 {
+			rank += nodeSize(n.Left) + 1
+			n = n.Right
+		} else {
+			n = n.Left
+		}
+	}
+	return depth, rank
+}
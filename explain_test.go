@@ -0,0 +1,39 @@
+package safe_treap
+
+import "testing"
+
+func TestExplainRange(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 1000)
+
+	for _, tc := range []struct {
+		lo, hi interface{}
+		keys   int
+	}{
+		{100, 199, 100}, {Unbounded, 9, 10}, {990, Unbounded, 10},
+		{Unbounded, Unbounded, 1000}, {500, 400, 0}, {2000, 3000, 0},
+	} {
+		p := tr.Explain(root, Query{Kind: QueryRange, Lo: tc.lo, Hi: tc.hi})
+		if p.Keys != tc.keys || p.Touched < p.Keys || p.Copied != 0 || p.Splits != 0 {
+			t.Errorf("range [%v, %v] planned as %+v, want %d keys", tc.lo, tc.hi, p, tc.keys)
+		}
+	}
+
+	p := tr.Explain(root, Query{Kind: QueryDeleteRange, Lo: 100, Hi: 199})
+	if p.Keys != 100 || p.Splits != 2 || p.Copied == 0 || p.Copied > 100 {
+		t.Errorf("range delete planned as %+v", p)
+	}
+}
+
+func TestExplainUnion(t *testing.T) {
+	tr := NewIntTreap()
+	big, small := fill(t, tr, 1000), fill(t, tr, 10)
+
+	p := tr.Explain(big, Query{Kind: QueryUnion, Other: small})
+	if p.Keys != 1010 || p.Splits != 10 || p.Touched == 0 || p.Touched > 1000 {
+		t.Errorf("union planned as %+v", p)
+	}
+	if p := tr.Explain(big, Query{Kind: QueryUnion}); p != (Plan{Keys: 1000}) {
+		t.Errorf("union with an empty treap planned as %+v", p)
+	}
+}
@@ -0,0 +1,158 @@
+package safe_treap
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+)
+
+// Filtered is a version of a treap paired with a bloom filter of its keys,
+// which lets lookups of absent keys return without a descent in most cases.
+//
+// Keys are added to the filter as they are inserted but never removed, and the
+// filter is shared by the versions derived from one another: an older version
+// may thus see keys of newer ones in its filter, which only costs a descent,
+// never a wrong answer.  Deleted and overwritten keys make the filter less
+// selective over time; Compact rebuilds it.  A Filtered is immutable and safe
+// for concurrent use.
+type Filtered struct {
+	t          *Treap
+	root       *Node
+	filter     *bloom
+	bitsPerKey int
+	hash       func(key interface{}) uint64
+}
+
+// NewFiltered pairs n with a filter of bitsPerKey bits per key, using hash to
+// hash the keys.  Keys equal under the key comparator must hash alike.  About
+// 10 bits per key give a false positive rate of 1%.
+func (t *Treap) NewFiltered(n *Node, bitsPerKey int, hash func(key interface{}) uint64) *Filtered {
+	f := &Filtered{t: t, root: n, bitsPerKey: bitsPerKey, hash: hash}
+	f.filter = f.build()
+	return f
+}
+
+func (f *Filtered) build() *bloom {
+	b := newBloom(nodeSize(f.root), f.bitsPerKey)
+	ascend(f.root, func(n *Node) bool {
+		b.add(f.hash(n.Key))
+		return true
+	})
+	return b
+}
+
+// with returns the version of f with root n, sharing its filter.
+func (f *Filtered) with(n *Node) *Filtered {
+	c := *f
+	c.root = n
+	return &c
+}
+
+// Root returns the root of the version.
+func (f *Filtered) Root() *Node {
+	return f.root
+}
+
+// Contains reports whether key is present.
+func (f *Filtered) Contains(key interface{}) bool {
+	_, ok := f.Get(key)
+	return ok
+}
+
+// Get returns the item of key, skipping the descent if the filter rules key
+// out.
+func (f *Filtered) Get(key interface{}) (interface{}, bool) {
+	if !f.filter.mayContain(f.hash(key)) {
+		return nil, false
+	}
+	return f.t.Get(f.root, key)
+}
+
+// Put returns a version where key holds val.
+func (f *Filtered) Put(key, val interface{}) *Filtered {
+	res, _ := f.t.Put(f.root, key, val)
+	if res == f.root {
+		return f
+	}
+	f.filter.add(f.hash(key))
+	return f.with(res)
+}
+
+// Delete returns a version without key.
+func (f *Filtered) Delete(key interface{}) *Filtered {
+	res, ok := f.t.Delete(f.root, key)
+	if !ok {
+		return f
+	}
+	return f.with(res)
+}
+
+// FalsePositiveRate estimates the probability that the filter lets through a
+// key that is absent, from the fraction of bits set.
+func (f *Filtered) FalsePositiveRate() float64 {
+	return f.filter.falsePositiveRate()
+}
+
+// Compact returns the version with a filter rebuilt for its current keys, of
+// the size they call for.  Versions keep the filter they were derived with.
+func (f *Filtered) Compact() *Filtered {
+	c := *f
+	c.filter = c.build()
+	return &c
+}
+
+// bloom is a bloom filter whose bits can be set concurrently.
+type bloom struct {
+	words []uint64
+	k     uint32
+}
+
+func newBloom(keys, bitsPerKey int) *bloom {
+	if bitsPerKey < 1 {
+		bitsPerKey = 1
+	}
+	m := keys * bitsPerKey
+	if m < 64 {
+		m = 64
+	}
+	k := uint32(math.Round(float64(bitsPerKey) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &bloom{words: make([]uint64, (m+63)/64), k: k}
+}
+
+// probe calls fn with the k bit positions of h, derived from its two halves
+// by double hashing.
+func (b *bloom) probe(h uint64, fn func(word int, mask uint64) bool) bool {
+	m := uint32(len(b.words) * 64)
+	h1, h2 := uint32(h), uint32(h>>32)|1
+	for i := uint32(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % m
+		if !fn(int(bit/64), 1<<(bit%64)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *bloom) add(h uint64) {
+	b.probe(h, func(word int, mask uint64) bool {
+		atomic.OrUint64(&b.words[word], mask)
+		return true
+	})
+}
+
+func (b *bloom) mayContain(h uint64) bool {
+	return b.probe(h, func(word int, mask uint64) bool {
+		return atomic.LoadUint64(&b.words[word])&mask != 0
+	})
+}
+
+func (b *bloom) falsePositiveRate() float64 {
+	set := 0
+	for i := range b.words {
+		set += bits.OnesCount64(atomic.LoadUint64(&b.words[i]))
+	}
+	return math.Pow(float64(set)/float64(len(b.words)*64), float64(b.k))
+}
@@ -0,0 +1,84 @@
+package safe_treap
+
+import (
+	"math/bits"
+	"testing"
+)
+
+func intHash(key interface{}) uint64 {
+	return bits.RotateLeft64(uint64(key.(int))*0x9e3779b97f4a7c15, 31) * 0xbf58476d1ce4e5b9
+}
+
+func TestFiltered(t *testing.T) {
+	var compares int
+	tr, err := NewTreap(&Handle{
+		CompareKeys: func(a, b interface{}) int {
+			compares++
+			return IntComparator(a, b)
+		},
+		CompareWeights: IntComparator,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := fill(t, tr, 1000)
+	f := tr.NewFiltered(root, 10, intHash)
+
+	for k := 0; k < 1000; k++ {
+		if v, ok := f.Get(k); !ok || v != k*10 {
+			t.Fatalf("Get(%d) = %v, %v", k, v, ok)
+		}
+	}
+
+	compares = 0
+	descents := 0
+	for k := 1000; k < 11000; k++ {
+		before := compares
+		if f.Contains(k) {
+			t.Fatalf("absent key %d found", k)
+		}
+		if compares > before {
+			descents++
+		}
+	}
+	if descents > 300 {
+		t.Errorf("%d of 10000 absent keys needed a descent", descents)
+	}
+	if rate := f.FalsePositiveRate(); rate > 0.03 {
+		t.Errorf("FalsePositiveRate = %v", rate)
+	}
+}
+
+func TestFilteredVersions(t *testing.T) {
+	tr := NewIntTreap()
+	f := tr.NewFiltered(fill(t, tr, 100), 10, intHash)
+
+	g := f.Put(500, "new")
+	if !g.Contains(500) || f.Contains(500) {
+		t.Error("Put is not persistent")
+	}
+
+	h := g.Delete(1)
+	if h.Contains(1) || !g.Contains(1) {
+		t.Error("Delete is not persistent")
+	}
+	if h.Delete(1) != h {
+		t.Error("deleting a missing key made a new version")
+	}
+	if tr.Len(h.Root()) != 100 {
+		t.Errorf("Len = %d", tr.Len(h.Root()))
+	}
+
+	for k := 0; k < 100; k++ {
+		h = h.Delete(k)
+	}
+	h = h.Put(1, "one")
+	compact := h.Compact()
+	if compact.FalsePositiveRate() >= h.FalsePositiveRate() {
+		t.Errorf("Compact did not make the filter more selective: %v, then %v",
+			h.FalsePositiveRate(), compact.FalsePositiveRate())
+	}
+	if !compact.Contains(1) || compact.Contains(2) {
+		t.Error("the compacted filter lost a key")
+	}
+}
@@ -0,0 +1,67 @@
+package safe_treap
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrWrongType is matched by the errors returned by the typed getters when a
+// value does not have the requested type.
+var ErrWrongType = errors.New("value has the wrong type")
+
+// TypeError reports a value that does not have the type a getter expected.
+type TypeError struct {
+	Key, Value interface{}
+	Want       string
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("value of key %v is %T, not %s", e.Key, e.Value, e.Want)
+}
+
+// Is makes errors.Is(err, ErrWrongType) hold.
+func (e *TypeError) Is(target error) bool {
+	return target == ErrWrongType
+}
+
+// GetString is like Get for treaps holding string values.  A value of another
+// type yields a *TypeError.
+func (t *Treap) GetString(n *Node, key interface{}) (string, bool, error) {
+	v, found := t.Get(n, key)
+	if !found {
+		return "", false, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", true, &TypeError{Key: key, Value: v, Want: "string"}
+	}
+	return s, true, nil
+}
+
+// GetInt is like Get for treaps holding int values.  A value of another type
+// yields a *TypeError.
+func (t *Treap) GetInt(n *Node, key interface{}) (int, bool, error) {
+	v, found := t.Get(n, key)
+	if !found {
+		return 0, false, nil
+	}
+	i, ok := v.(int)
+	if !ok {
+		return 0, true, &TypeError{Key: key, Value: v, Want: "int"}
+	}
+	return i, true, nil
+}
+
+// GetBytes is like Get for treaps holding []byte values.  A value of another
+// type yields a *TypeError.
+func (t *Treap) GetBytes(n *Node, key interface{}) ([]byte, bool, error) {
+	v, found := t.Get(n, key)
+	if !found {
+		return nil, false, nil
+	}
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, true, &TypeError{Key: key, Value: v, Want: "[]byte"}
+	}
+	return b, true, nil
+}
@@ -1,3 +1,3 @@
 module github.com/fearblackcat/safe-treap
 
-go 1.14
+go 1.23
@@ -0,0 +1,53 @@
+package safe_treap
+
+import "errors"
+
+// ErrUnorderedGroups is returned by GroupBy when the classifier is not
+// monotone over the key order.
+var ErrUnorderedGroups = errors.New("group keys are not in ascending order")
+
+// GroupBy buckets the entries of n by classify(key), which must be monotone
+// over the key order (e.g. truncating timestamps to the day or hour).
+//
+// The result is a treap keyed by group key whose items are the roots (*Node)
+// of the sub-treaps holding the entries of each group.  Group keys are ordered
+// with the handle's key comparator, every entry keeps its original weight, and
+// each group takes the weight of its sub-treap root.  The whole operation is a
+// single O(n) ordered pass; n itself is not modified.
+func (t *Treap) GroupBy(n *Node, classify func(key interface{}) interface{}) (*Node, error) {
+	var (
+		groups  = newBuilder(t.h())
+		current *builder
+		group   interface{}
+		err     error
+	)
+
+	flush := func() {
+		if current != nil {
+			sub := current.root()
+			g := t.newNode()
+			g.Weight, g.Key, g.Item = sub.Weight, group, sub
+			groups.push(g)
+		}
+	}
+
+	ascend(n, func(n *Node) bool {
+		g := classify(n.Key)
+		if current == nil || t.h().CompareKeys(g, group) != 0 {
+			if current != nil && t.h().CompareKeys(g, group) < 0 {
+				err = ErrUnorderedGroups
+				return false
+			}
+			flush()
+			current, group = newBuilder(t.h()), g
+		}
+		current.push(t.clone(n, nil, nil))
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	flush()
+
+	return groups.root(), nil
+}
@@ -0,0 +1,62 @@
+package safe_treap
+
+import "testing"
+
+func TestGroupBy(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 95)
+	groups, err := tr.GroupBy(root, func(k interface{}) interface{} { return k.(int) / 10 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Validate(groups); err != nil {
+		t.Fatal(err)
+	}
+	if tr.Len(groups) != 10 {
+		t.Fatalf("%d groups", tr.Len(groups))
+	}
+
+	for g := 0; g < 10; g++ {
+		n, ok := tr.GetNode(groups, g)
+		if !ok {
+			t.Fatalf("group %d is missing", g)
+		}
+		sub := n.Item.(*Node)
+		if err := tr.Validate(sub); err != nil {
+			t.Fatalf("group %d: %v", g, err)
+		}
+		if n.Weight != sub.Weight {
+			t.Errorf("group %d has weight %d, its root %d", g, n.Weight, sub.Weight)
+		}
+		want := 10
+		if g == 9 {
+			want = 5
+		}
+		if tr.Len(sub) != want {
+			t.Errorf("group %d holds %d keys", g, tr.Len(sub))
+		}
+		ascend(sub, func(e *Node) bool {
+			orig, _ := tr.GetNode(root, e.Key)
+			if e.Key.(int)/10 != g || e.Weight != orig.Weight || e.Item != orig.Item {
+				t.Errorf("group %d holds %v/%d, originally %v/%d", g, e.Key, e.Weight, orig.Item, orig.Weight)
+			}
+			return true
+		})
+	}
+	if tr.Len(root) != 95 {
+		t.Error("GroupBy modified its input")
+	}
+
+	if groups, err := tr.GroupBy(nil, func(k interface{}) interface{} { return k }); groups != nil || err != nil {
+		t.Errorf("GroupBy of an empty treap = %v, %v", groups, err)
+	}
+}
+
+func TestGroupByUnordered(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 20)
+	_, err := tr.GroupBy(root, func(k interface{}) interface{} { return k.(int) % 3 })
+	if err != ErrUnorderedGroups {
+		t.Errorf("a classifier that is not monotone: %v", err)
+	}
+}
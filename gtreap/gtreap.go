@@ -0,0 +1,93 @@
+// Package gtreap mirrors the API of github.com/steveyen/gtreap on top of
+// safe_treap, so that code written against gtreap can move over by changing
+// an import path.
+//
+// As in gtreap, a Treap value is immutable: Upsert and Delete return a new
+// Treap and leave the receiver untouched, items act as their own keys, and
+// items with a higher priority sit closer to the root.
+package gtreap
+
+import (
+	treap "github.com/fearblackcat/safe-treap"
+)
+
+// Compare returns an integer comparing two items lexicographically: 0 if
+// a == b, negative if a < b, positive if a > b.
+type Compare func(a, b interface{}) int
+
+// Item is an element of the treap.
+type Item interface{}
+
+// ItemVisitor is called on the items of a visit and returns false to stop.
+type ItemVisitor func(i Item) bool
+
+// Treap is an immutable treap of items.
+type Treap struct {
+	t    *treap.Treap
+	root *treap.Node
+}
+
+// NewTreap creates an empty treap of items ordered by c.
+func NewTreap(c Compare) *Treap {
+	t, _ := treap.NewTreap(&treap.Handle{
+		CompareKeys: treap.Comparator(c),
+		// gtreap keeps the highest priority at the root.
+		CompareWeights: treap.MaxHeap(),
+	})
+	return &Treap{t: t}
+}
+
+// Min returns the smallest item, or nil if the treap is empty.
+func (t *Treap) Min() Item {
+	n := t.root
+	if n == nil {
+		return nil
+	}
+	for n.Left != nil {
+		n = n.Left
+	}
+	return n.Item
+}
+
+// Max returns the greatest item, or nil if the treap is empty.
+func (t *Treap) Max() Item {
+	n := t.root
+	if n == nil {
+		return nil
+	}
+	for n.Right != nil {
+		n = n.Right
+	}
+	return n.Item
+}
+
+// Get returns the item equal to target, or nil if there is none.
+func (t *Treap) Get(target Item) Item {
+	item, _ := t.t.Get(t.root, target)
+	return item
+}
+
+// Upsert returns a treap in which item replaces the item equal to it, or is
+// added if there is none, with the given priority.
+func (t *Treap) Upsert(item Item, itemPriority int) *Treap {
+	return t.apply(treap.Op{Kind: treap.OpPut, Key: item, Value: item, Weight: itemPriority})
+}
+
+// Delete returns a treap without the item equal to target.
+func (t *Treap) Delete(target Item) *Treap {
+	return t.apply(treap.Op{Kind: treap.OpDelete, Key: target})
+}
+
+func (t *Treap) apply(op treap.Op) *Treap {
+	// the treap has no limits, so Apply cannot fail.
+	root, _ := t.t.Apply(t.root, []treap.Op{op})
+	return &Treap{t: t.t, root: root}
+}
+
+// VisitAscend calls visitor on the items greater than or equal to pivot, in
+// ascending order, until it returns false.
+func (t *Treap) VisitAscend(pivot Item, visitor ItemVisitor) {
+	t.t.View(t.root, pivot, treap.Unbounded).Ascend(func(n *treap.Node) bool {
+		return visitor(n.Item)
+	})
+}
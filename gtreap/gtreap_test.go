@@ -0,0 +1,62 @@
+package gtreap
+
+import (
+	"strings"
+	"testing"
+)
+
+func stringCompare(a, b interface{}) int {
+	return strings.Compare(a.(string), b.(string))
+}
+
+func TestTreap(t *testing.T) {
+	empty := NewTreap(stringCompare)
+	if empty.Min() != nil || empty.Max() != nil || empty.Get("a") != nil {
+		t.Error("an empty treap has items")
+	}
+
+	x := empty.Upsert("b", 1).Upsert("a", 5).Upsert("c", 3)
+	if x.Min() != "a" || x.Max() != "c" || x.Get("b") != "b" {
+		t.Errorf("Min = %v, Max = %v, Get(b) = %v", x.Min(), x.Max(), x.Get("b"))
+	}
+	// the highest priority is the root.
+	if x.root.Item != "a" {
+		t.Errorf("root = %v, want the item of priority 5", x.root.Item)
+	}
+	if empty.Get("a") != nil {
+		t.Error("Upsert modified its receiver")
+	}
+
+	y := x.Delete("a")
+	if y.Get("a") != nil || x.Get("a") != "a" {
+		t.Error("Delete is not persistent")
+	}
+	if y.Delete("missing").Min() != "b" {
+		t.Error("deleting a missing item changed the treap")
+	}
+}
+
+func TestVisitAscend(t *testing.T) {
+	x := NewTreap(stringCompare)
+	for i, s := range []string{"d", "a", "c", "e", "b"} {
+		x = x.Upsert(s, i)
+	}
+
+	var got []string
+	x.VisitAscend("b", func(i Item) bool {
+		got = append(got, i.(string))
+		return i != "d"
+	})
+	if strings.Join(got, "") != "bcd" {
+		t.Errorf("VisitAscend(b) visited %v", got)
+	}
+
+	got = nil
+	x.VisitAscend("bb", func(i Item) bool {
+		got = append(got, i.(string))
+		return true
+	})
+	if strings.Join(got, "") != "cde" {
+		t.Errorf("VisitAscend(bb) visited %v", got)
+	}
+}
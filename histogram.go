@@ -0,0 +1,35 @@
+package safe_treap
+
+// Bucket is a key range of an equi-depth histogram.  Lo and Hi are the
+// smallest and greatest keys of the bucket.
+type Bucket struct {
+	Lo, Hi interface{}
+	Count  int
+}
+
+// Histogram divides the keys of n into at most buckets ranges holding the
+// same number of keys (give or take one), for selectivity estimation.
+//
+// The bounds of every bucket are found by position using the subtree sizes,
+// so the cost is O(buckets log n) rather than a walk of the whole treap.
+func (t *Treap) Histogram(n *Node, buckets int) []Bucket {
+	total := nodeSize(n)
+	if total == 0 || buckets <= 0 {
+		return nil
+	}
+	if buckets > total {
+		buckets = total
+	}
+
+	res := make([]Bucket, buckets)
+	for i := range res {
+		// bucket i holds the keys at positions [total*i/buckets, total*(i+1)/buckets)
+		first, end := total*i/buckets, total*(i+1)/buckets
+		res[i] = Bucket{
+			Lo:    selectNode(n, first).Key,
+			Hi:    selectNode(n, end-1).Key,
+			Count: end - first,
+		}
+	}
+	return res
+}
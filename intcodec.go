@@ -0,0 +1,110 @@
+package safe_treap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// DeltaIntCodec encodes records with int keys in ascending order, such as the
+// output of EncodeCanonical or SendSnapshot, storing every key as the varint
+// difference from the previous one.  Dense or clustered ID keys then take a
+// byte or two instead of a full gob-encoded interface value.
+//
+// Weights are stored as varints and items are encoded with the items codec,
+// whose decoder must not read ahead of the record it decodes: GobCodec
+// qualifies.  Encoding a key that is not an int greater than the previous one
+// fails with ErrUnsorted.
+func DeltaIntCodec(items Codec) Codec {
+	return deltaIntCodec{items: items}
+}
+
+type deltaIntCodec struct {
+	items Codec
+}
+
+func (c deltaIntCodec) NewEncoder(w io.Writer) Encoder {
+	return &deltaIntEncoder{w: w, items: c.items.NewEncoder(w)}
+}
+
+func (c deltaIntCodec) NewDecoder(r io.Reader) Decoder {
+	// the item decoder shares the buffered reader, so that it picks up
+	// exactly where the key and weight end.
+	br := bufio.NewReader(r)
+	return &deltaIntDecoder{r: br, items: c.items.NewDecoder(br)}
+}
+
+type deltaIntEncoder struct {
+	w     io.Writer
+	items Encoder
+	last  int
+	count int
+}
+
+func (e *deltaIntEncoder) Encode(rec *Record) error {
+	key, ok := rec.Key.(int)
+	if !ok {
+		return fmt.Errorf("delta int codec: key %v is %T, not int", rec.Key, rec.Key)
+	}
+	if e.count > 0 && key <= e.last {
+		return ErrUnsorted
+	}
+
+	var buf [2 * binary.MaxVarintLen64]byte
+	var n int
+	if e.count == 0 {
+		n = binary.PutVarint(buf[:], int64(key))
+	} else {
+		n = binary.PutUvarint(buf[:], uint64(key-e.last))
+	}
+	n += binary.PutVarint(buf[n:], int64(rec.Weight))
+	if _, err := e.w.Write(buf[:n]); err != nil {
+		return err
+	}
+	if err := e.items.Encode(&Record{Item: rec.Item}); err != nil {
+		return err
+	}
+
+	e.last = key
+	e.count++
+	return nil
+}
+
+type deltaIntDecoder struct {
+	r     *bufio.Reader
+	items Decoder
+	last  int
+	count int
+}
+
+func (d *deltaIntDecoder) Decode(rec *Record) error {
+	var key int
+	if d.count == 0 {
+		k, err := binary.ReadVarint(d.r)
+		if err != nil {
+			return err
+		}
+		key = int(k)
+	} else {
+		delta, err := binary.ReadUvarint(d.r)
+		if err != nil {
+			return err
+		}
+		key = d.last + int(delta)
+	}
+
+	weight, err := binary.ReadVarint(d.r)
+	if err != nil {
+		return unexpected(err)
+	}
+	var item Record
+	if err := d.items.Decode(&item); err != nil {
+		return unexpected(err)
+	}
+
+	*rec = Record{Key: key, Item: item.Item, Weight: int(weight)}
+	d.last = key
+	d.count++
+	return nil
+}
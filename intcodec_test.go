@@ -0,0 +1,51 @@
+package safe_treap
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestDeltaIntCodec(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 1000)
+	root, _ = tr.Insert(root, math.MinInt, "min", -5)
+	root, _ = tr.Insert(root, math.MaxInt, "max", 5)
+	codec := DeltaIntCodec(GobCodec)
+
+	var buf, plain bytes.Buffer
+	if err := EncodeCanonical(&buf, root, codec); err != nil {
+		t.Fatal(err)
+	}
+	EncodeCanonical(&plain, root, GobCodec)
+	if buf.Len() >= plain.Len() {
+		t.Errorf("delta encoding takes %d bytes, gob %d", buf.Len(), plain.Len())
+	}
+
+	got, err := tr.DecodeSorted(bytes.NewReader(buf.Bytes()), codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, r, c := tr.Diff(root, got); len(a)+len(r)+len(c) != 0 {
+		t.Errorf("decoded treap differs: +%v -%v ~%v", a, r, c)
+	}
+	if n, _ := tr.GetNode(got, math.MinInt); n.Weight != -5 {
+		t.Errorf("weight of the smallest key = %d", n.Weight)
+	}
+
+	if _, err := tr.DecodeSorted(bytes.NewReader(buf.Bytes()[:buf.Len()-1]), codec); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated stream: %v", err)
+	}
+}
+
+func TestDeltaIntCodecErrors(t *testing.T) {
+	enc := DeltaIntCodec(GobCodec).NewEncoder(io.Discard)
+	if err := enc.Encode(&Record{Key: "a"}); err == nil {
+		t.Error("a string key was encoded")
+	}
+	enc.Encode(&Record{Key: 2})
+	if err := enc.Encode(&Record{Key: 2}); err != ErrUnsorted {
+		t.Errorf("repeated key: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package safe_treap
+
+import "iter"
+
+// All returns an iterator over the keys and items of n in key order, for use
+// with range:
+//
+//	for k, v := range t.All(root) {
+//		...
+//	}
+func (t *Treap) All(n *Node) iter.Seq2[interface{}, interface{}] {
+	return func(yield func(interface{}, interface{}) bool) {
+		ascend(n, func(n *Node) bool {
+			return yield(n.Key, t.value(n.Item))
+		})
+	}
+}
+
+// Descend returns an iterator over the keys and items of n in reverse key
+// order, from the greatest key to the least, e.g. to read the latest entries
+// of a treap keyed by timestamps.
+func (t *Treap) Descend(n *Node) iter.Seq2[interface{}, interface{}] {
+	return func(yield func(interface{}, interface{}) bool) {
+		descend(n, func(n *Node) bool {
+			return yield(n.Key, t.value(n.Item))
+		})
+	}
+}
+
+// Range returns an iterator over the keys of n in [lo, hi] and their items in
+// key order.  Either bound may be Unbounded.  Subtrees outside of the range
+// are not visited.
+func (t *Treap) Range(n *Node, lo, hi interface{}) iter.Seq2[interface{}, interface{}] {
+	return func(yield func(interface{}, interface{}) bool) {
+		t.ascendRange(n, lo, hi, func(n *Node) bool {
+			return yield(n.Key, t.value(n.Item))
+		})
+	}
+}
+
+// DescendRange is like Range in reverse key order, from hi down to lo.
+func (t *Treap) DescendRange(n *Node, hi, lo interface{}) iter.Seq2[interface{}, interface{}] {
+	return func(yield func(interface{}, interface{}) bool) {
+		t.descendRange(n, lo, hi, func(n *Node) bool {
+			return yield(n.Key, t.value(n.Item))
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package safe_treap
+
+import "testing"
+
+func collect(seq func(func(interface{}, interface{}) bool)) []interface{} {
+	var keys []interface{}
+	for k := range seq {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func TestRange(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 100)
+
+	keys := collect(tr.Range(root, 20, 24))
+	if len(keys) != 5 || keys[0] != 20 || keys[4] != 24 {
+		t.Errorf("Range(20, 24) = %v", keys)
+	}
+	if keys := collect(tr.DescendRange(root, 24, 20)); len(keys) != 5 || keys[0] != 24 || keys[4] != 20 {
+		t.Errorf("DescendRange(24, 20) = %v", keys)
+	}
+	if keys := collect(tr.Range(root, 95, Unbounded)); len(keys) != 5 || keys[4] != 99 {
+		t.Errorf("Range(95, Unbounded) = %v", keys)
+	}
+	if keys := collect(tr.DescendRange(root, 2, Unbounded)); len(keys) != 3 || keys[2] != 0 {
+		t.Errorf("DescendRange(2, Unbounded) = %v", keys)
+	}
+	if keys := collect(tr.Range(root, 30, 20)); len(keys) != 0 {
+		t.Errorf("an inverted range yielded %v", keys)
+	}
+	if len(collect(tr.All(root))) != 100 || collect(tr.Descend(root))[0] != 99 {
+		t.Error("All or Descend missed keys")
+	}
+
+	for k, v := range tr.Range(root, 50, Unbounded) {
+		if k != 50 || v != 500 {
+			t.Errorf("first entry %v = %v", k, v)
+		}
+		break
+	}
+
+	if got := tr.AppendRange(make([]KV, 0, 8), root, 10, 12); len(got) != 3 || got[2] != (KV{12, 120}) {
+		t.Errorf("AppendRange = %v", got)
+	}
+}
+
+func TestRangePrunes(t *testing.T) {
+	var compares int
+	tr, err := NewTreap(&Handle{
+		CompareKeys: func(a, b interface{}) int {
+			compares++
+			return IntComparator(a, b)
+		},
+		CompareWeights: IntComparator,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := fill(t, tr, 10000)
+
+	compares = 0
+	for range tr.Range(root, 5000, 5009) {
+	}
+	// two comparisons per node on or next to the paths of the bounds.
+	if compares > 400 {
+		t.Errorf("a range of 10 keys out of 10000 took %d comparisons", compares)
+	}
+}
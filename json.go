@@ -0,0 +1,118 @@
+package safe_treap
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+)
+
+// jsonEntry is the JSON form of an entry.
+type jsonEntry struct {
+	Key    json.RawMessage `json:"key"`
+	Value  json.RawMessage `json:"value"`
+	Weight *int            `json:"weight,omitempty"`
+}
+
+// WithJSONDecoder makes UnmarshalJSON decode the keys (key true) and values of
+// the entries with decode, to restore types that do not survive JSON as is.
+// The default decodes integral numbers as int, other numbers as float64, and
+// everything else as encoding/json does into an interface{}.
+func WithJSONDecoder(decode func(data []byte, key bool) (interface{}, error)) Option {
+	return func(t *Treap) {
+		t.jsonDecode = decode
+	}
+}
+
+// MarshalJSON implements json.Marshaler, encoding the current root (see
+// LoadRoot) as an array of {"key", "value", "weight"} objects in key order.
+// The keys and their weights determine the shape of the treap, so
+// UnmarshalJSON rebuilds it as balanced as it was.
+func (t *Treap) MarshalJSON() ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		err error
+	)
+	buf.WriteByte('[')
+	ascend(t.LoadRoot(), func(n *Node) bool {
+		var e jsonEntry
+		w := n.Weight
+		e.Weight = &w
+		if e.Key, err = json.Marshal(n.Key); err != nil {
+			return false
+		}
+		if e.Value, err = json.Marshal(t.value(n.Item)); err != nil {
+			return false
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		var data []byte
+		data, err = json.Marshal(e)
+		buf.Write(data)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, replacing the root of the treap
+// with the entries of data, as written by MarshalJSON.  The entries must be in
+// ascending key order (or ErrUnsorted is returned); those without a weight get
+// a random one.
+func (t *Treap) UnmarshalJSON(data []byte) error {
+	var entries []jsonEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	decode := t.jsonDecode
+	if decode == nil {
+		decode = decodeJSON
+	}
+	b := newBuilder(t.h())
+	var last *Node
+	for _, e := range entries {
+		n := t.newNode()
+		var err error
+		if n.Key, err = decode(e.Key, true); err != nil {
+			return err
+		}
+		if n.Item, err = decode(e.Value, false); err != nil {
+			return err
+		}
+		if e.Weight != nil {
+			n.Weight = *e.Weight
+		} else {
+			n.Weight = t.randomWeight()
+		}
+		if last != nil && t.h().CompareKeys(n.Key, last.Key) <= 0 {
+			return ErrUnsorted
+		}
+		b.push(n)
+		last = n
+	}
+
+	t.root.Store(b.root())
+	return nil
+}
+
+// decodeJSON is the default decoder of WithJSONDecoder.
+func decodeJSON(data []byte, _ bool) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	num, ok := v.(json.Number)
+	if !ok {
+		return v, nil
+	}
+	if i, err := num.Int64(); err == nil && i >= math.MinInt && i <= math.MaxInt {
+		return int(i), nil
+	}
+	return num.Float64()
+}
@@ -0,0 +1,74 @@
+package safe_treap
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 100)
+	root, _ = tr.Put(root, 100, "string")
+	root, _ = tr.Put(root, 101, 1.5)
+	tr.CompareAndSwapRoot(nil, root)
+
+	data, err := json.Marshal(struct{ Index *Treap }{tr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct{ Index *Treap }
+	got.Index = NewIntTreap()
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	res := got.Index.LoadRoot()
+	if err := tr.Validate(res); err != nil {
+		t.Fatal(err)
+	}
+	if a, r, c := tr.Diff(root, res); len(a)+len(r)+len(c) != 0 {
+		t.Errorf("decoded treap differs: +%v -%v ~%v", a, r, c)
+	}
+	for k := 0; k < 102; k++ {
+		want, _ := tr.GetNode(root, k)
+		if n, _ := tr.GetNode(res, k); n.Weight != want.Weight {
+			t.Fatalf("key %d has weight %d, want %d", k, n.Weight, want.Weight)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tr := NewIntTreap()
+	if err := tr.UnmarshalJSON([]byte(`[{"key":1,"value":"a"},{"key":2,"value":[1,2]}]`)); err != nil {
+		t.Fatal(err)
+	}
+	if tr.Len(tr.LoadRoot()) != 2 {
+		t.Errorf("Len = %d, want 2", tr.Len(tr.LoadRoot()))
+	}
+	if err := tr.UnmarshalJSON([]byte(`[{"key":2,"value":0},{"key":1,"value":0}]`)); err != ErrUnsorted {
+		t.Errorf("unsorted entries: %v", err)
+	}
+	if err := tr.UnmarshalJSON([]byte(`{}`)); err == nil {
+		t.Error("an object was accepted")
+	}
+
+	upper, err := NewTreap(&Handle{CompareKeys: StringComparator, CompareWeights: IntComparator},
+		WithJSONDecoder(func(data []byte, key bool) (interface{}, error) {
+			var s string
+			err := json.Unmarshal(data, &s)
+			if key {
+				s = strings.ToUpper(s)
+			}
+			return s, err
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := upper.UnmarshalJSON([]byte(`[{"key":"a","value":"x"}]`)); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := upper.Get(upper.LoadRoot(), "A"); !ok || v != "x" {
+		t.Errorf("Get(A) = %v, %v with a custom decoder", v, ok)
+	}
+}
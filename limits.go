@@ -0,0 +1,99 @@
+package safe_treap
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTooLarge is matched by the errors returned when a key or value exceeds
+// the limits set with WithMaxKeySize or WithMaxValueSize.  ErrKeyTooLarge and
+// ErrValueTooLarge tell the two cases apart.
+var (
+	ErrTooLarge      = errors.New("entry too large")
+	ErrKeyTooLarge   = errors.New("key too large")
+	ErrValueTooLarge = errors.New("value too large")
+)
+
+// SizeError reports a key or value over its size limit.
+type SizeError struct {
+	Field     string // "key" or "value"
+	Size, Max int
+}
+
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("%s of size %d exceeds the limit of %d", e.Field, e.Size, e.Max)
+}
+
+// Is makes errors.Is(err, ErrTooLarge) hold, as well as ErrKeyTooLarge or
+// ErrValueTooLarge depending on the field.
+func (e *SizeError) Is(target error) bool {
+	switch target {
+	case ErrTooLarge:
+		return true
+	case ErrKeyTooLarge:
+		return e.Field == "key"
+	case ErrValueTooLarge:
+		return e.Field == "value"
+	}
+	return false
+}
+
+// sizeLimit bounds the size of keys or values as measured by sizeof.
+type sizeLimit struct {
+	max    int
+	sizeof func(interface{}) int
+}
+
+// WithMaxKeySize rejects writes of keys larger than max.  sizeof measures a
+// key; if nil, strings and byte slices are measured by their length and other
+// types are not limited.
+//
+// The limits are enforced by the writes that return an error: the checked
+// writes (InsertChecked, InsertStrict, UpsertChecked, PutChecked), Apply,
+// Replay, Transient and the writes of SafeTreap, Memtable and MultiIndex,
+// which fail with a *SizeError and leave the treap unchanged.  Insert, Upsert,
+// Put and InsertMax cannot report the error and do not check the limits.
+func WithMaxKeySize(max int, sizeof func(key interface{}) int) Option {
+	return func(t *Treap) {
+		t.maxKeySize = sizeLimit{max: max, sizeof: sizeof}
+	}
+}
+
+// WithMaxValueSize is the counterpart of WithMaxKeySize for values.
+func WithMaxValueSize(max int, sizeof func(val interface{}) int) Option {
+	return func(t *Treap) {
+		t.maxValueSize = sizeLimit{max: max, sizeof: sizeof}
+	}
+}
+
+// check returns a *SizeError if v is over the limit.
+func (l sizeLimit) check(field string, v interface{}) error {
+	if l.max <= 0 {
+		return nil
+	}
+
+	size := 0
+	if l.sizeof != nil {
+		size = l.sizeof(v)
+	} else {
+		switch v := v.(type) {
+		case string:
+			size = len(v)
+		case []byte:
+			size = len(v)
+		}
+	}
+
+	if size > l.max {
+		return &SizeError{Field: field, Size: size, Max: l.max}
+	}
+	return nil
+}
+
+// checkSizes enforces the key and value size limits of t.
+func (t *Treap) checkSizes(key, val interface{}) error {
+	if err := t.maxKeySize.check("key", key); err != nil {
+		return err
+	}
+	return t.maxValueSize.check("value", val)
+}
@@ -0,0 +1,103 @@
+package safe_treap
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func newLimitedTreap(t *testing.T) *Treap {
+	t.Helper()
+	tr, err := NewTreap(&Handle{CompareKeys: StringComparator, CompareWeights: IntComparator},
+		WithMaxKeySize(4, nil), WithMaxValueSize(8, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+func TestCheckedWritesSizeLimits(t *testing.T) {
+	tr := newLimitedTreap(t)
+	root, _, err := tr.InsertChecked(nil, "a", "value", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		write func() (*Node, bool, error)
+		want  error
+	}{
+		{"InsertChecked key", func() (*Node, bool, error) { return tr.InsertChecked(root, "large", "v", 0) }, ErrKeyTooLarge},
+		{"UpsertChecked value", func() (*Node, bool, error) { return tr.UpsertChecked(root, "a", "too large", 0) }, ErrValueTooLarge},
+		{"PutChecked value", func() (*Node, bool, error) { return tr.PutChecked(root, "a", "too large") }, ErrValueTooLarge},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, ok, err := tc.write()
+			if !errors.Is(err, tc.want) || !errors.Is(err, ErrTooLarge) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+			var se *SizeError
+			if !errors.As(err, &se) || se.Max == 0 {
+				t.Errorf("err = %#v, want a *SizeError", err)
+			}
+			if res != root || ok {
+				t.Error("a rejected write changed the treap")
+			}
+		})
+	}
+
+	if _, created, err := tr.UpsertChecked(root, "b", "value", 0); !created || err != nil {
+		t.Errorf("UpsertChecked of a new key = %v, %v", created, err)
+	}
+}
+
+func TestApplyLimits(t *testing.T) {
+	tr := newLimitedTreap(t)
+	root, err := tr.Apply(nil, []Op{{Kind: OpPut, Key: "a", Value: "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := tr.Apply(root, []Op{
+		{Kind: OpPut, Key: "b", Value: "2"},
+		{Kind: OpDelete, Key: "a"},
+		{Kind: OpInsert, Key: "c", Value: "too large"},
+	})
+	if !errors.Is(err, ErrValueTooLarge) {
+		t.Fatalf("Apply of an oversized op: %v", err)
+	}
+	if res != root {
+		t.Error("a failed batch was partly applied")
+	}
+}
+
+func TestReplayLimits(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewWAL(&buf, GobCodec)
+	l.Upsert("a", "1", 0)
+	l.Upsert("large", "2", 0)
+	l.Upsert("b", "3", 0)
+
+	tr := newLimitedTreap(t)
+	root, applied, err := tr.Replay(nil, &buf, GobCodec)
+	if !errors.Is(err, ErrKeyTooLarge) {
+		t.Fatalf("Replay of an oversized record: %v", err)
+	}
+	if applied != 1 || tr.Len(root) != 1 {
+		t.Errorf("applied %d records, %d keys, want 1", applied, tr.Len(root))
+	}
+}
+
+func TestSafeTreapLimits(t *testing.T) {
+	s := NewSafeTreap(newLimitedTreap(t))
+	if _, err := s.Put("large", "v"); !errors.Is(err, ErrKeyTooLarge) {
+		t.Errorf("Put of an oversized key: %v", err)
+	}
+	if _, err := s.Upsert("a", "too large", 0); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Upsert of an oversized value: %v", err)
+	}
+	if s.Len() != 0 {
+		t.Errorf("rejected writes left %d keys", s.Len())
+	}
+}
@@ -0,0 +1,64 @@
+package safe_treap
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrKeyCollision is returned by MapKeys and RebuildIndex when two keys are
+// mapped to the same new key.
+var ErrKeyCollision = errors.New("mapped keys collide")
+
+// MapKeys builds a new treap holding the entries of n with every key replaced
+// by f(key).  Items and weights are kept.
+//
+// The new keys are ordered with t's key comparator, which need not be the one
+// n was built with: to migrate keys to another type, call MapKeys on a Treap
+// created with a handle for the new key type.  Entries are re-sorted only when
+// f does not preserve the key order.  If two entries map to the same key an
+// error wrapping ErrKeyCollision is returned.
+//
+// O(n) if f preserves the order of the keys, O(n log n) otherwise.
+func (t *Treap) MapKeys(n *Node, f func(k interface{}) interface{}) (*Node, error) {
+	var nodes []*Node
+	ascend(n, func(n *Node) bool {
+		c := t.clone(n, nil, nil)
+		c.Key = f(n.Key)
+		nodes = append(nodes, c)
+		return true
+	})
+	return t.mappedRoot(t.buildUnsorted(nodes))
+}
+
+// RebuildIndex derives a secondary index from the primary treap src in one
+// bulk pass: every entry of src becomes an entry keyed by keyFn(key, val),
+// whose item is the primary key and whose weight is weightFn(key, val), or a
+// random weight if weightFn is nil.  The index keys are ordered with t's key
+// comparator, and an error wrapping ErrKeyCollision is returned if two
+// entries have the same index key.
+//
+// O(n log n), or O(n) if the index keys follow the order of the primary keys.
+func (t *Treap) RebuildIndex(src *Node, keyFn func(key, val interface{}) interface{}, weightFn func(key, val interface{}) int) (*Node, error) {
+	var nodes []*Node
+	ascend(src, func(n *Node) bool {
+		c := t.newNode()
+		c.Key, c.Item = keyFn(n.Key, n.Item), n.Key
+		if weightFn != nil {
+			c.Weight = weightFn(n.Key, n.Item)
+		} else {
+			c.Weight = t.randomWeight()
+		}
+		nodes = append(nodes, c)
+		return true
+	})
+	return t.mappedRoot(t.buildUnsorted(nodes))
+}
+
+// mappedRoot turns the duplicate reported by buildUnsorted into an
+// ErrKeyCollision.
+func (t *Treap) mappedRoot(root, dup *Node) (*Node, error) {
+	if dup != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeyCollision, dup.Key)
+	}
+	return root, nil
+}
@@ -0,0 +1,76 @@
+package safe_treap
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestMapKeys(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 100)
+
+	// order preserving, into another key type.
+	strs := NewStringTreap()
+	mapped, err := strs.MapKeys(root, func(k interface{}) interface{} { return fmt.Sprintf("%03d", k) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := strs.Validate(mapped); err != nil {
+		t.Fatal(err)
+	}
+	n, _ := strs.GetNode(mapped, "042")
+	orig, _ := tr.GetNode(root, 42)
+	if n.Item != 420 || n.Weight != orig.Weight || strs.Len(mapped) != 100 {
+		t.Errorf("042 holds %v with weight %d, want 420 with %d", n.Item, n.Weight, orig.Weight)
+	}
+
+	// order reversing.
+	negated, err := tr.MapKeys(root, func(k interface{}) interface{} { return -k.(int) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Validate(negated); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := tr.Get(negated, -99); v != 990 {
+		t.Errorf("Get(-99) = %v", v)
+	}
+	if v, _ := tr.Get(root, 99); v != 990 {
+		t.Error("MapKeys modified its input")
+	}
+}
+
+func TestMapKeysCollision(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 10)
+	_, err := tr.MapKeys(root, func(k interface{}) interface{} { return k.(int) / 2 })
+	if !errors.Is(err, ErrKeyCollision) {
+		t.Errorf("colliding keys: %v", err)
+	}
+}
+
+func TestRebuildIndex(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 50)
+	index, err := tr.RebuildIndex(root, func(key, val interface{}) interface{} { return val }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Validate(index); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := tr.Get(index, 230); id != 23 {
+		t.Errorf("index entry 230 points at %v", id)
+	}
+
+	weighted, _ := tr.RebuildIndex(root, func(key, val interface{}) interface{} { return -key.(int) },
+		func(key, val interface{}) int { return key.(int) })
+	if weighted.Key != 0 || weighted.Weight != 0 {
+		t.Errorf("the lightest entry is not the root: %v", weighted.Key)
+	}
+
+	if _, err := tr.RebuildIndex(root, func(key, val interface{}) interface{} { return 0 }, nil); !errors.Is(err, ErrKeyCollision) {
+		t.Errorf("colliding index keys: %v", err)
+	}
+}
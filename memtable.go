@@ -0,0 +1,163 @@
+package safe_treap
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// ErrFrozen is the misuse of writing to a Memtable that was flushed (see
+// MisusePolicy).
+var ErrFrozen = errors.New("memtable is frozen")
+
+// Tombstone is the item recorded by Memtable.Delete.  It is flushed like any
+// other item so that the deletion shadows older values in lower levels of an
+// LSM tree.
+var Tombstone interface{} = tombstone{}
+
+type tombstone struct{}
+
+func init() {
+	gob.Register(tombstone{})
+}
+
+// nodeOverhead is the estimated memory used by a node besides its key and
+// item.
+const nodeOverhead = int(unsafe.Sizeof(Node{}))
+
+// Memtable is the write buffer of an LSM storage engine: a sorted in-memory
+// table absorbing writes until it is flushed as a sorted string table.
+//
+// Reads never block and see a consistent version of the table.  Flush freezes
+// the table, so that the flushed file holds every write the memtable ever
+// accepted; later writes fail with ErrFrozen and belong in a new memtable.
+type Memtable struct {
+	t      *Treap
+	codec  Codec
+	sizeof func(interface{}) int
+
+	mu     sync.Mutex // serializes writers
+	root   atomic.Value
+	size   int64
+	frozen int32
+}
+
+// NewMemtable creates an empty memtable ordered by t and flushed with codec.
+// sizeof estimates the memory used by a key or item for ApproximateSize; if
+// nil, strings and byte slices count for their length and other values for
+// nothing besides the node holding them.
+func NewMemtable(t *Treap, codec Codec, sizeof func(interface{}) int) *Memtable {
+	m := &Memtable{t: t, codec: codec, sizeof: sizeof}
+	m.root.Store((*Node)(nil))
+	return m
+}
+
+func (m *Memtable) load() *Node {
+	return m.root.Load().(*Node)
+}
+
+// Get returns the item of key.  A deleted key is found with Tombstone as its
+// item, telling the caller not to look further down the LSM tree.
+func (m *Memtable) Get(key interface{}) (interface{}, bool) {
+	return m.t.Get(m.load(), key)
+}
+
+// Put sets the item of key.  Entries over the size limits of the treap fail
+// with a *SizeError, and keys too deep for its depth limit with
+// ErrDepthExceeded.
+func (m *Memtable) Put(key, val interface{}) error {
+	return m.write(key, val)
+}
+
+// Delete records a Tombstone for key.
+func (m *Memtable) Delete(key interface{}) error {
+	return m.write(key, Tombstone)
+}
+
+func (m *Memtable) write(key, val interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Frozen() {
+		return m.t.misuse(ErrFrozen)
+	}
+
+	root := m.load()
+	if err := m.checkSizes(key, val); err != nil {
+		return err
+	}
+	if err := m.t.checkDepth(root, key, true); err != nil {
+		return err
+	}
+
+	delta := m.entrySize(key, val)
+	if old, ok := m.t.Get(root, key); ok {
+		delta -= m.entrySize(key, old)
+	}
+
+	res, _ := m.t.Put(root, key, val)
+	if res == root {
+		return nil // unchanged (see WithEqualValues)
+	}
+	m.root.Store(res)
+	atomic.AddInt64(&m.size, int64(delta))
+	return nil
+}
+
+// checkSizes enforces the size limits of the treap; a Tombstone is not a
+// value and is never limited.
+func (m *Memtable) checkSizes(key, val interface{}) error {
+	if val == Tombstone {
+		return m.t.maxKeySize.check("key", key)
+	}
+	return m.t.checkSizes(key, val)
+}
+
+func (m *Memtable) entrySize(key, val interface{}) int {
+	return nodeOverhead + m.valueSize(key) + m.valueSize(val)
+}
+
+func (m *Memtable) valueSize(v interface{}) int {
+	if m.sizeof != nil {
+		return m.sizeof(v)
+	}
+	return approximateSize(v)
+}
+
+// approximateSize is the default estimate of the memory used by a key or
+// item: the length of strings and byte slices, nothing for other values.
+func approximateSize(v interface{}) int {
+	switch v := v.(type) {
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	}
+	return 0
+}
+
+// ApproximateSize estimates the memory used by the entries of the table, to
+// decide when to flush it.  Memory shared with versions held by readers is
+// not counted.
+func (m *Memtable) ApproximateSize() int {
+	return int(atomic.LoadInt64(&m.size))
+}
+
+// Frozen reports whether the table was flushed.
+func (m *Memtable) Frozen() bool {
+	return atomic.LoadInt32(&m.frozen) == 1
+}
+
+// Flush freezes the table and writes all of its entries, tombstones included,
+// to w in key order with the codec of the table.  It may be called again, e.g.
+// to retry after a failed write, and always writes the same entries.
+func (m *Memtable) Flush(w io.Writer) error {
+	m.mu.Lock()
+	atomic.StoreInt32(&m.frozen, 1)
+	m.mu.Unlock()
+
+	return EncodeCanonical(w, m.load(), m.codec)
+}
@@ -0,0 +1,88 @@
+package safe_treap
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMemtable(t *testing.T) {
+	tr := NewStringTreap()
+	m := NewMemtable(tr, GobCodec, nil)
+	for _, k := range []string{"b", "a", "c"} {
+		if err := m.Put(k, "value-"+k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Delete("b"); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := m.Get("b"); !ok || v != Tombstone {
+		t.Errorf("Get of a deleted key = %v, %v", v, ok)
+	}
+	if v, _ := m.Get("a"); v != "value-a" {
+		t.Errorf("Get(a) = %v", v)
+	}
+
+	// a, c and the tombstone of b: keys plus items plus node overhead.
+	if want := 3*nodeOverhead + 3 + 2*len("value-a"); m.ApproximateSize() != want {
+		t.Errorf("ApproximateSize = %d, want %d", m.ApproximateSize(), want)
+	}
+
+	var buf bytes.Buffer
+	if err := m.Flush(&buf); err != nil {
+		t.Fatal(err)
+	}
+	flushedLen := buf.Len()
+	if !m.Frozen() {
+		t.Error("Flush did not freeze the table")
+	}
+	flushed, err := tr.DecodeSorted(&buf, GobCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := tr.Get(flushed, "b"); v != Tombstone || tr.Len(flushed) != 3 {
+		t.Errorf("flushed %d entries, b = %v", tr.Len(flushed), v)
+	}
+
+	if err := m.Put("d", "x"); err != ErrFrozen {
+		t.Errorf("Put after Flush: %v", err)
+	}
+	if err := m.Delete("a"); err != ErrFrozen {
+		t.Errorf("Delete after Flush: %v", err)
+	}
+	var again bytes.Buffer
+	m.Flush(&again)
+	if again.Len() == 0 || again.Len() != flushedLen {
+		t.Errorf("a second Flush wrote %d bytes, the first %d", again.Len(), flushedLen)
+	}
+}
+
+func TestMemtableSizeof(t *testing.T) {
+	m := NewMemtable(NewIntTreap(), GobCodec, func(interface{}) int { return 10 })
+	m.Put(1, "a")
+	m.Put(1, "bb")
+	if want := nodeOverhead + 20; m.ApproximateSize() != want {
+		t.Errorf("ApproximateSize after a replacement = %d, want %d", m.ApproximateSize(), want)
+	}
+}
+
+func TestMemtableLimits(t *testing.T) {
+	m := NewMemtable(newLimitedTreap(t), GobCodec, nil)
+	if err := m.Put("large", "v"); !errors.Is(err, ErrKeyTooLarge) {
+		t.Errorf("Put of an oversized key: %v", err)
+	}
+	if err := m.Put("k", "too large"); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Put of an oversized value: %v", err)
+	}
+	// tombstones are not values and only the key is limited.
+	if err := m.Delete("k"); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+	if err := m.Delete("large"); !errors.Is(err, ErrKeyTooLarge) {
+		t.Errorf("Delete of an oversized key: %v", err)
+	}
+	if _, ok := m.Get("large"); ok {
+		t.Error("a rejected write was stored")
+	}
+}
@@ -0,0 +1,182 @@
+package safe_treap
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrNoMerkle is the misuse of hashing a treap created without WithMerkle
+// (see MisusePolicy).
+var ErrNoMerkle = errors.New("treap has no Merkle encoding")
+
+// WithMerkle makes the treap authenticated: RootHash hashes every node from
+// its key, item and weight, encoded with marshal, and the hashes of its
+// children, so that the hash of a root commits to the whole treap, shape
+// included.  marshal must be deterministic, and the same for every treap
+// sharing nodes.
+//
+// Hashes are computed on demand and cached in the nodes, which never change
+// once published: hashing a new version only hashes the nodes it does not
+// share with versions hashed before.
+func WithMerkle(marshal func(v interface{}) []byte) Option {
+	return func(t *Treap) {
+		t.marshal = marshal
+	}
+}
+
+// RootHash returns the SHA-256 hash of n, or nil for an empty treap.
+func (t *Treap) RootHash(n *Node) ([]byte, error) {
+	if t.marshal == nil {
+		return nil, t.misuse(ErrNoMerkle)
+	}
+	if n == nil {
+		return nil, nil
+	}
+	h := t.nodeHash(n)
+	return h[:], nil
+}
+
+func (t *Treap) nodeHash(n *Node) *digest {
+	if n == nil {
+		return nil
+	}
+	if h := n.hash.Load(); h != nil {
+		return h
+	}
+	h := merkleHash(t.marshal(n.Key), t.marshal(n.Item), n.Weight, t.nodeHash(n.Left), t.nodeHash(n.Right))
+	n.hash.Store(h)
+	return h
+}
+
+// digest is the hash of a node.
+type digest [sha256.Size]byte
+
+// merkleHash hashes a node as
+//
+//	len(key) | key | len(value) | value | weight | left | right
+//
+// with lengths as uvarints, the weight as 8 bytes big endian and the hash of
+// a missing child as 32 zero bytes.
+func merkleHash(key, value []byte, weight int, left, right *digest) *digest {
+	var zero digest
+	if left == nil {
+		left = &zero
+	}
+	if right == nil {
+		right = &zero
+	}
+
+	buf := make([]byte, 0, 2*binary.MaxVarintLen64+len(key)+len(value)+8+2*sha256.Size)
+	buf = binary.AppendUvarint(buf, uint64(len(key)))
+	buf = append(buf, key...)
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	buf = append(buf, value...)
+	buf = binary.BigEndian.AppendUint64(buf, uint64(int64(weight)))
+	buf = append(buf, left[:]...)
+	buf = append(buf, right[:]...)
+	h := digest(sha256.Sum256(buf))
+	return &h
+}
+
+// ProofStep is an ancestor of the proven node: its encoded entry, the hash of
+// the child off the path (nil if missing), and whether the path goes on to
+// its left child.
+type ProofStep struct {
+	Key, Value []byte
+	Weight     int
+	Sibling    []byte
+	Left       bool
+}
+
+// Proof shows that an entry belongs to the treap with a given root hash: it
+// holds the hashes of the children of the node and the ancestors of the node
+// from its parent up to the root.
+type Proof struct {
+	Weight      int
+	Left, Right []byte
+	Path        []ProofStep
+}
+
+// Prove returns a proof that key is in n, and false if it is not.  The proof
+// has one step per ancestor of the node holding key.
+func (t *Treap) Prove(n *Node, key interface{}) (*Proof, bool, error) {
+	if t.marshal == nil {
+		return nil, false, t.misuse(ErrNoMerkle)
+	}
+
+	var path []ProofStep
+	for n != nil {
+		comp := t.h().CompareKeys(key, n.Key)
+		if comp == 0 {
+			break
+		}
+		step := ProofStep{Key: t.marshal(n.Key), Value: t.marshal(n.Item), Weight: n.Weight, Left: comp < 0}
+		sibling := n.Left
+		if step.Left {
+			sibling = n.Right
+		}
+		step.Sibling = hashBytes(t.nodeHash(sibling))
+		path = append(path, step)
+		if step.Left {
+			n = n.Left
+		} else {
+			n = n.Right
+		}
+	}
+	if n == nil {
+		return nil, false, nil
+	}
+
+	// the steps run from the parent of the node up to the root.
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return &Proof{
+		Weight: n.Weight,
+		Left:   hashBytes(t.nodeHash(n.Left)),
+		Right:  hashBytes(t.nodeHash(n.Right)),
+		Path:   path,
+	}, true, nil
+}
+
+// VerifyProof reports whether p proves that the entry with the encoded key
+// and value belongs to the treap whose root hash is root.
+func VerifyProof(root, key, value []byte, p *Proof) bool {
+	left, ok1 := hashArray(p.Left)
+	right, ok2 := hashArray(p.Right)
+	if !ok1 || !ok2 {
+		return false
+	}
+	h := merkleHash(key, value, p.Weight, left, right)
+	for _, step := range p.Path {
+		sibling, ok := hashArray(step.Sibling)
+		if !ok {
+			return false
+		}
+		if step.Left {
+			h = merkleHash(step.Key, step.Value, step.Weight, h, sibling)
+		} else {
+			h = merkleHash(step.Key, step.Value, step.Weight, sibling, h)
+		}
+	}
+	return bytes.Equal(h[:], root)
+}
+
+func hashBytes(h *digest) []byte {
+	if h == nil {
+		return nil
+	}
+	return h[:]
+}
+
+func hashArray(b []byte) (*digest, bool) {
+	if b == nil {
+		return nil, true
+	}
+	if len(b) != sha256.Size {
+		return nil, false
+	}
+	return (*digest)(b), true
+}
@@ -0,0 +1,87 @@
+package safe_treap
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func sprintBytes(v interface{}) []byte {
+	return []byte(fmt.Sprint(v))
+}
+
+func newMerkleTreap(t *testing.T) *Treap {
+	t.Helper()
+	tr, err := NewTreap(&Handle{CompareKeys: IntComparator, CompareWeights: IntComparator}, WithMerkle(sprintBytes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+func TestRootHash(t *testing.T) {
+	tr := newMerkleTreap(t)
+	root := fill(t, tr, 200)
+	h, err := tr.RootHash(root)
+	if err != nil || len(h) != 32 {
+		t.Fatalf("RootHash = %x, %v", h, err)
+	}
+	if h2, _ := tr.RootHash(root); !bytes.Equal(h, h2) {
+		t.Error("hash of the same root changed")
+	}
+
+	changed, _ := tr.Put(root, 100, "changed")
+	if h2, _ := tr.RootHash(changed); bytes.Equal(h, h2) {
+		t.Error("changing an item kept the hash")
+	}
+
+	// a treap of the same entries and weights built apart hashes the same.
+	var recs []Record
+	ascend(root, func(n *Node) bool {
+		recs = append(recs, Record{Key: n.Key, Item: n.Item, Weight: n.Weight})
+		return true
+	})
+	other, err := tr.FromPairsWithWeights(recs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h2, _ := tr.RootHash(other); !bytes.Equal(h, h2) {
+		t.Error("equal treaps have different hashes")
+	}
+
+	if h, err := tr.RootHash(nil); h != nil || err != nil {
+		t.Errorf("RootHash(nil) = %x, %v", h, err)
+	}
+	if _, err := NewIntTreap().RootHash(root); err != ErrNoMerkle {
+		t.Errorf("RootHash without WithMerkle: %v", err)
+	}
+}
+
+func TestProve(t *testing.T) {
+	tr := newMerkleTreap(t)
+	root := fill(t, tr, 200)
+	h, _ := tr.RootHash(root)
+	other, _ := tr.Put(root, 0, "other")
+	otherHash, _ := tr.RootHash(other)
+
+	for _, k := range []int{0, 57, 199} {
+		p, ok, err := tr.Prove(root, k)
+		if !ok || err != nil {
+			t.Fatalf("Prove(%d) = %v, %v", k, ok, err)
+		}
+		key, val := sprintBytes(k), sprintBytes(k*10)
+		if !VerifyProof(h, key, val, p) {
+			t.Errorf("proof of %d does not verify", k)
+		}
+		if VerifyProof(h, key, sprintBytes("forged"), p) {
+			t.Errorf("proof of %d verifies a forged value", k)
+		}
+		if VerifyProof(otherHash, key, val, p) {
+			t.Errorf("proof of %d verifies against another root", k)
+		}
+	}
+
+	if _, ok, _ := tr.Prove(root, 1000); ok {
+		t.Error("Prove found a missing key")
+	}
+}
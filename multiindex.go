@@ -0,0 +1,112 @@
+package safe_treap
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// MultiIndex keeps a primary treap of id → value consistent with a secondary
+// treap keyed by an attribute of the values (e.g. a timestamp), whose items
+// are the ids.
+//
+// Both roots are published together after every change, so a reader always
+// sees two indexes describing the same set of values.  Writers are
+// serialized; readers never block.
+type MultiIndex struct {
+	primary, secondary *Treap
+	secondaryKey       func(val interface{}) interface{}
+
+	mu    sync.Mutex // serializes writers
+	roots atomic.Value
+}
+
+type indexRoots struct {
+	primary, secondary *Node
+}
+
+// NewMultiIndex creates an empty index.  secondaryKey derives the secondary
+// key of a value; it must be unique across the values of the index, so a
+// timestamp should be combined with something that tells equal ones apart.
+func NewMultiIndex(primary, secondary *Treap, secondaryKey func(val interface{}) interface{}) *MultiIndex {
+	m := &MultiIndex{primary: primary, secondary: secondary, secondaryKey: secondaryKey}
+	m.roots.Store(indexRoots{})
+	return m
+}
+
+// Roots returns the current roots of the primary and secondary treaps.
+func (m *MultiIndex) Roots() (primary, secondary *Node) {
+	r := m.roots.Load().(indexRoots)
+	return r.primary, r.secondary
+}
+
+// Get returns the value stored under id.
+func (m *MultiIndex) Get(id interface{}) (interface{}, bool) {
+	primary, _ := m.Roots()
+	return m.primary.Get(primary, id)
+}
+
+// Lookup returns the id and value whose secondary key is key.
+func (m *MultiIndex) Lookup(key interface{}) (id, val interface{}, found bool) {
+	r := m.roots.Load().(indexRoots)
+	if id, found = m.secondary.Get(r.secondary, key); found {
+		val, _ = m.primary.Get(r.primary, id)
+	}
+	return
+}
+
+// Put stores val under id, moving the secondary entry if the secondary key of
+// the value changed.  If another id already owns the secondary key of val,
+// nothing is changed and a *KeyExistsError naming that key is returned.  The
+// writes to both treaps are checked like PutChecked, and nothing is changed
+// if one of them fails.
+func (m *MultiIndex) Put(id, val interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r := m.roots.Load().(indexRoots)
+	key := m.secondaryKey(val)
+	if owner, ok := m.secondary.Get(r.secondary, key); ok && m.primary.h().CompareKeys(owner, id) != 0 {
+		return &KeyExistsError{Key: key}
+	}
+
+	old, existed := m.primary.Get(r.primary, id)
+	primary, _, err := m.primary.PutChecked(r.primary, id, val)
+	if err != nil {
+		return err
+	}
+	if primary == r.primary {
+		return nil // unchanged (see WithEqualValues)
+	}
+
+	secondary := r.secondary
+	if existed {
+		if secondary, _, err = m.secondary.DeleteChecked(secondary, m.secondaryKey(old)); err != nil {
+			return err
+		}
+	}
+	if secondary, _, err = m.secondary.PutChecked(secondary, key, id); err != nil {
+		return err
+	}
+
+	m.roots.Store(indexRoots{primary: primary, secondary: secondary})
+	return nil
+}
+
+// Delete removes id and its secondary entry, returning false if id was not
+// in the index.
+func (m *MultiIndex) Delete(id interface{}) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r := m.roots.Load().(indexRoots)
+	old, ok := m.primary.Get(r.primary, id)
+	if !ok {
+		return false
+	}
+
+	r.primary, _ = m.primary.Delete(r.primary, id)
+	r.secondary, _ = m.secondary.Delete(r.secondary, m.secondaryKey(old))
+
+	m.roots.Store(r)
+	return true
+}
@@ -0,0 +1,76 @@
+package safe_treap
+
+import (
+	"errors"
+	"testing"
+)
+
+type event struct {
+	name string
+	at   int
+}
+
+func newEventIndex(t *testing.T, opts ...Option) *MultiIndex {
+	t.Helper()
+	secondary, err := NewTreap(&Handle{CompareKeys: IntComparator, CompareWeights: IntComparator}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewMultiIndex(NewStringTreap(), secondary, func(val interface{}) interface{} {
+		return val.(event).at
+	})
+}
+
+func TestMultiIndex(t *testing.T) {
+	m := newEventIndex(t)
+	for i, name := range []string{"a", "b", "c"} {
+		if err := m.Put(name, event{name, i * 10}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if id, val, ok := m.Lookup(10); !ok || id != "b" || val.(event).name != "b" {
+		t.Errorf("Lookup(10) = %v, %v, %v", id, val, ok)
+	}
+
+	// moving b to another time drops its old secondary entry.
+	if err := m.Put("b", event{"b", 15}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := m.Lookup(10); ok {
+		t.Error("the old secondary key of b is still indexed")
+	}
+	if id, _, _ := m.Lookup(15); id != "b" {
+		t.Errorf("Lookup(15) = %v", id)
+	}
+
+	var exists *KeyExistsError
+	if err := m.Put("d", event{"d", 20}); !errors.As(err, &exists) || exists.Key != 20 {
+		t.Errorf("Put of a taken secondary key: %v", err)
+	}
+	if _, ok := m.Get("d"); ok {
+		t.Error("a conflicting Put was stored")
+	}
+
+	if !m.Delete("a") || m.Delete("a") {
+		t.Error("Delete did not report its key once")
+	}
+	primary, secondary := m.Roots()
+	if nodeSize(primary) != 2 || nodeSize(secondary) != 2 {
+		t.Errorf("indexes hold %d and %d entries", nodeSize(primary), nodeSize(secondary))
+	}
+}
+
+func TestMultiIndexSecondaryLimits(t *testing.T) {
+	m := newEventIndex(t, WithMaxValueSize(1, nil))
+	if err := m.Put("a", event{"a", 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Put("long", event{"long", 2}); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Put of an id too large for the secondary index: %v", err)
+	}
+	primary, secondary := m.Roots()
+	if nodeSize(primary) != 1 || nodeSize(secondary) != 1 {
+		t.Errorf("a failed Put left %d primary and %d secondary entries", nodeSize(primary), nodeSize(secondary))
+	}
+}
@@ -0,0 +1,72 @@
+package safe_treap
+
+// Option configures a Treap created with NewTreap.
+type Option func(*Treap)
+
+// WithMaxDepth bounds the number of nodes a descent may visit, counting the
+// leaf an insertion adds.  The checked operations (GetChecked, InsertChecked,
+// UpsertChecked, PutChecked, DeleteChecked) and the writes built on them
+// (Apply, Transient, SafeTreap, Memtable, MultiIndex) fail with
+// ErrDepthExceeded instead of walking further, so that a treap degraded by
+// badly distributed weights is reported rather than silently becoming O(n).
+// The depth of a treap depends on its data, so this is an error rather than
+// a misuse.  The unchecked operations ignore the limit.  A depth <= 0
+// disables the limit, which is the default.
+func WithMaxDepth(d int) Option {
+	return func(t *Treap) {
+		t.maxDepth = d
+	}
+}
+
+// WithCopyOnRead makes reads hand out copy(item) instead of the item stored in
+// the treap: Get and the other lookups return copies, and visitors receive
+// copies of the nodes whose Item is a copy.  Items of a persistent treap are
+// shared by every version that contains them, so a caller mutating an item it
+// was given would otherwise corrupt all of those versions.  GetNode is the
+// exception: it returns the stored node itself.
+func WithCopyOnRead(copy func(item interface{}) interface{}) Option {
+	return func(t *Treap) {
+		t.copyOnRead = copy
+	}
+}
+
+// WithEqualValues makes writes that would not change anything return the
+// root they were given: Put and Upsert (and OpPut in Apply) compare the new
+// value to the old one with eq and, if equal and the weight is unchanged,
+// neither copy the path nor build a new root.  Callers that publish roots can
+// then detect idempotent writes with a pointer comparison.
+func WithEqualValues(eq func(a, b interface{}) bool) Option {
+	return func(t *Treap) {
+		t.equal = eq
+	}
+}
+
+// unchanged reports whether writing val with weight over the node old is a
+// no-op according to WithEqualValues.
+func (t *Treap) unchanged(old *Node, val interface{}, weight int) bool {
+	return t.equal != nil && old.Weight == weight && t.equal(old.Item, val)
+}
+
+// value returns the item v as handed out to readers.
+func (t *Treap) value(v interface{}) interface{} {
+	if t.copyOnRead == nil {
+		return v
+	}
+	return t.copyOnRead(v)
+}
+
+// visible returns the node n as handed out to visitors.
+func (t *Treap) visible(n *Node) *Node {
+	if t.copyOnRead == nil {
+		return n
+	}
+	return &Node{
+		Weight: n.Weight,
+		Key:    n.Key,
+		Item:   t.copyOnRead(n.Item),
+		Meta:   n.Meta,
+		Left:   n.Left,
+		Right:  n.Right,
+		size:   n.size,
+	}
+}
@@ -0,0 +1,40 @@
+package safe_treap
+
+import "sync"
+
+// ForEachParallel calls fn on every node of n, splitting the keys into up to
+// workers ranges of equal size that are visited concurrently, each in key
+// order.  It returns once every call completed.  fn must be safe for
+// concurrent use.
+//
+// The ranges are found by position with the subtree sizes, in O(log n) each,
+// and walked without copying anything, so the treap is processed in place.
+func (t *Treap) ForEachParallel(n *Node, workers int, fn func(*Node)) {
+	total := nodeSize(n)
+	if workers > total {
+		workers = total
+	}
+	if workers <= 1 {
+		ascend(n, func(n *Node) bool {
+			fn(t.visible(n))
+			return true
+		})
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		lo := selectNode(n, total*i/workers).Key
+		hi := selectNode(n, total*(i+1)/workers-1).Key
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.ascendRange(n, lo, hi, func(n *Node) bool {
+				fn(t.visible(n))
+				return true
+			})
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,100 @@
+package safe_treap
+
+import (
+	"sort"
+	"time"
+)
+
+// TimePartitioned keeps one treap per time bucket of a fixed width, so that
+// old entries expire by dropping whole buckets instead of deleting keys one
+// by one, as for the retention of metrics or log indexes.
+//
+// Queries see the union of the buckets, the newest bucket winning for keys
+// present in several of them.  A TimePartitioned is not safe for concurrent
+// use.
+type TimePartitioned struct {
+	t       *Treap
+	width   time.Duration
+	buckets []partition // by ascending start
+}
+
+type partition struct {
+	start time.Time
+	root  *Node
+}
+
+// NewTimePartitioned creates an empty partitioned treap with buckets of the
+// given width, ordered by t.
+func NewTimePartitioned(t *Treap, width time.Duration) *TimePartitioned {
+	return &TimePartitioned{t: t, width: width}
+}
+
+// Put sets the item of key in the bucket holding the time at, creating the
+// bucket if needed.  It returns true if key was created in that bucket.
+func (p *TimePartitioned) Put(at time.Time, key, val interface{}) bool {
+	start := at.Truncate(p.width)
+	i := sort.Search(len(p.buckets), func(i int) bool {
+		return !p.buckets[i].start.Before(start)
+	})
+	if i == len(p.buckets) || !p.buckets[i].start.Equal(start) {
+		p.buckets = append(p.buckets, partition{})
+		copy(p.buckets[i+1:], p.buckets[i:])
+		p.buckets[i] = partition{start: start}
+	}
+
+	var created bool
+	p.buckets[i].root, created = p.t.Put(p.buckets[i].root, key, val)
+	return created
+}
+
+// Get returns the item of key in the newest bucket holding it.
+//
+// O(b log n) for b buckets.
+func (p *TimePartitioned) Get(key interface{}) (interface{}, bool) {
+	for i := len(p.buckets) - 1; i >= 0; i-- {
+		if v, ok := p.t.Get(p.buckets[i].root, key); ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Root returns the union of all the buckets as a single root, which can be
+// passed to the methods of the Treap.  The buckets are left unchanged.
+func (p *TimePartitioned) Root() *Node {
+	var n *Node
+	for i := len(p.buckets) - 1; i >= 0; i-- {
+		n = p.t.Union(n, p.buckets[i].root)
+	}
+	return n
+}
+
+// Ascend visits the entries of all the buckets in key order until fn returns
+// false.
+func (p *TimePartitioned) Ascend(fn func(*Node) bool) {
+	ascend(p.Root(), func(n *Node) bool {
+		return fn(p.t.visible(n))
+	})
+}
+
+// Buckets returns the start times of the buckets, oldest first.
+func (p *TimePartitioned) Buckets() []time.Time {
+	starts := make([]time.Time, len(p.buckets))
+	for i, b := range p.buckets {
+		starts[i] = b.start
+	}
+	return starts
+}
+
+// Expire drops the buckets ending at or before cutoff, returning how many
+// were dropped.  Each bucket is dropped in O(1), whatever its size.
+func (p *TimePartitioned) Expire(cutoff time.Time) int {
+	i := sort.Search(len(p.buckets), func(i int) bool {
+		return p.buckets[i].start.Add(p.width).After(cutoff)
+	})
+	for j := range p.buckets[:i] {
+		p.buckets[j] = partition{} // release the roots
+	}
+	p.buckets = p.buckets[i:]
+	return i
+}
@@ -0,0 +1,52 @@
+package safe_treap
+
+import "sync/atomic"
+
+// MisusePolicy decides what happens when the package detects that it is being
+// misused, e.g. a treap created without a Handle or a write to a persister
+// that was closed.  Libraries embedding a treap usually want errors they can
+// pass on; applications often prefer to crash at the faulty call site.
+type MisusePolicy int32
+
+const (
+	// inheritPolicy makes a Treap follow the package-wide policy.
+	inheritPolicy MisusePolicy = iota
+	// ReturnErrors reports misuse as an error, the default.
+	ReturnErrors
+	// PanicOnMisuse panics with the error describing the misuse.
+	PanicOnMisuse
+)
+
+var misusePolicy = int32(ReturnErrors)
+
+// SetMisusePolicy sets the package-wide policy, which applies to treaps that
+// were not given one with WithMisusePolicy and to helpers that are not tied
+// to a treap.  It is safe to call at any time.
+func SetMisusePolicy(p MisusePolicy) {
+	atomic.StoreInt32(&misusePolicy, int32(p))
+}
+
+// WithMisusePolicy overrides the package-wide misuse policy for one treap.
+func WithMisusePolicy(p MisusePolicy) Option {
+	return func(t *Treap) {
+		t.policy = p
+	}
+}
+
+// misuse applies the policy of t to err, the description of a misuse.
+func (t *Treap) misuse(err error) error {
+	p := t.policy
+	if p == inheritPolicy {
+		p = MisusePolicy(atomic.LoadInt32(&misusePolicy))
+	}
+	if p == PanicOnMisuse {
+		panic(err)
+	}
+	return err
+}
+
+// misuse applies the package-wide policy to err.
+func misuse(err error) error {
+	var t Treap
+	return t.misuse(err)
+}
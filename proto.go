@@ -0,0 +1,158 @@
+package safe_treap
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// ErrBadProto is returned by FromProto for data that is not a valid Snapshot
+// message.
+var ErrBadProto = errors.New("malformed treap snapshot message")
+
+// protobuf wire types and the field tags of treap.proto.
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireBytes  = 2
+	wireI32    = 5
+
+	tagSnapshotEntries = 1<<3 | wireBytes
+	tagEntryKey        = 1<<3 | wireBytes
+	tagEntryValue      = 2<<3 | wireBytes
+	tagEntryWeight     = 3<<3 | wireVarint
+)
+
+// ToProto encodes the entries of n as a Snapshot message of treap.proto, in
+// key order, marshaling keys and items to bytes with marshal.  The encoding is
+// the one protobuf implementations produce for the same message, so it can be
+// shipped over gRPC as is.
+func (t *Treap) ToProto(n *Node, marshal func(v interface{}) ([]byte, error)) ([]byte, error) {
+	var (
+		buf, entry []byte
+		err        error
+	)
+	ascend(n, func(n *Node) bool {
+		var key, value []byte
+		if key, err = marshal(n.Key); err != nil {
+			return false
+		}
+		if value, err = marshal(t.value(n.Item)); err != nil {
+			return false
+		}
+
+		entry = entry[:0]
+		if len(key) > 0 {
+			entry = appendBytesField(entry, tagEntryKey, key)
+		}
+		if len(value) > 0 {
+			entry = appendBytesField(entry, tagEntryValue, value)
+		}
+		if n.Weight != 0 {
+			entry = binary.AppendUvarint(entry, tagEntryWeight)
+			entry = binary.AppendUvarint(entry, uint64(int64(n.Weight)))
+		}
+		buf = appendBytesField(buf, tagSnapshotEntries, entry)
+		return true
+	})
+	return buf, err
+}
+
+// FromProto builds a treap from a Snapshot message, unmarshaling keys and
+// items with unmarshalKey and unmarshalValue and keeping the weights.  The
+// entries must be in ascending key order, or ErrUnsorted is returned.
+// Unknown fields are skipped, as protobuf requires.
+func (t *Treap) FromProto(data []byte, unmarshalKey, unmarshalValue func([]byte) (interface{}, error)) (*Node, error) {
+	b := newBuilder(t.h())
+	var last *Node
+	err := protoFields(data, func(tag uint64, field []byte, _ uint64) error {
+		if tag != tagSnapshotEntries {
+			return nil
+		}
+
+		var key, value []byte
+		var weight uint64
+		err := protoFields(field, func(tag uint64, field []byte, x uint64) error {
+			switch tag {
+			case tagEntryKey:
+				key = field
+			case tagEntryValue:
+				value = field
+			case tagEntryWeight:
+				weight = x
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		n := t.newNode()
+		n.Weight = int(int64(weight))
+		if n.Key, err = unmarshalKey(key); err != nil {
+			return err
+		}
+		if n.Item, err = unmarshalValue(value); err != nil {
+			return err
+		}
+		if last != nil && t.h().CompareKeys(n.Key, last.Key) <= 0 {
+			return ErrUnsorted
+		}
+		b.push(n)
+		last = n
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.root(), nil
+}
+
+func appendBytesField(buf []byte, tag uint64, field []byte) []byte {
+	buf = binary.AppendUvarint(buf, tag)
+	buf = binary.AppendUvarint(buf, uint64(len(field)))
+	return append(buf, field...)
+}
+
+// protoFields calls fn for every field of the message data, with the payload
+// of length-delimited fields or the value of varint fields.
+func protoFields(data []byte, fn func(tag uint64, field []byte, x uint64) error) error {
+	for len(data) > 0 {
+		tag, k := binary.Uvarint(data)
+		if k <= 0 {
+			return ErrBadProto
+		}
+		data = data[k:]
+
+		var field []byte
+		var x uint64
+		switch tag & 7 {
+		case wireVarint:
+			if x, k = binary.Uvarint(data); k <= 0 {
+				return ErrBadProto
+			}
+			data = data[k:]
+		case wireI64, wireI32:
+			size := 8
+			if tag&7 == wireI32 {
+				size = 4
+			}
+			if len(data) < size {
+				return ErrBadProto
+			}
+			data = data[size:]
+		case wireBytes:
+			size, k := binary.Uvarint(data)
+			if k <= 0 || uint64(len(data)-k) < size {
+				return ErrBadProto
+			}
+			field, data = data[k:k+int(size)], data[k+int(size):]
+		default:
+			return ErrBadProto
+		}
+
+		if err := fn(tag, field, x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package safe_treap
+
+import (
+	"bytes"
+	"testing"
+)
+
+func protoString(v interface{}) ([]byte, error) { return []byte(v.(string)), nil }
+
+func protoUnstring(b []byte) (interface{}, error) { return string(b), nil }
+
+func TestProtoRoundTrip(t *testing.T) {
+	tr := NewStringTreap()
+	var root *Node
+	for i, k := range []string{"m", "c", "x", "a", "q"} {
+		root, _ = tr.Insert(root, k, k+k, i-2) // negative weights too
+	}
+
+	data, err := tr.ToProto(root, protoString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tr.FromProto(data, protoUnstring, protoUnstring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Validate(got); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"m", "c", "x", "a", "q"} {
+		want, _ := tr.GetNode(root, k)
+		n, ok := tr.GetNode(got, k)
+		if !ok || n.Item != want.Item || n.Weight != want.Weight {
+			t.Errorf("key %s decoded as %+v", k, n)
+		}
+	}
+}
+
+func TestProtoWireFormat(t *testing.T) {
+	tr := NewStringTreap()
+	root, _ := tr.Insert(nil, "a", "b", 1)
+	data, err := tr.ToProto(root, protoString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x0a, 0x08, 0x0a, 0x01, 'a', 0x12, 0x01, 'b', 0x18, 0x01}
+	if !bytes.Equal(data, want) {
+		t.Fatalf("ToProto = % x, want % x", data, want)
+	}
+
+	// unknown fields are skipped.
+	got, err := tr.FromProto(append(data, 0x10, 0x05), protoUnstring, protoUnstring)
+	if err != nil || tr.Len(got) != 1 {
+		t.Errorf("FromProto with an unknown field = %d keys, %v", tr.Len(got), err)
+	}
+
+	if _, err := tr.FromProto(data[:len(data)-1], protoUnstring, protoUnstring); err != ErrBadProto {
+		t.Errorf("truncated message: %v", err)
+	}
+	unsorted := append(append([]byte(nil), data...), data...)
+	if _, err := tr.FromProto(unsorted, protoUnstring, protoUnstring); err != ErrUnsorted {
+		t.Errorf("repeated key: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package safe_treap
+
+import "sync"
+
+// rangeLocks are the ranges held with SafeTreap.LockRange.
+type rangeLocks struct {
+	mu       sync.Mutex
+	released *sync.Cond // broadcast whenever a range is unlocked
+	held     []*keyRange
+}
+
+type keyRange struct {
+	lo, hi interface{}
+}
+
+// LockRange blocks until no range overlapping the keys between lo and hi
+// inclusive is locked, locks that range and returns the function unlocking
+// it.  Either bound may be Unbounded; an empty range (lo > hi) overlaps
+// nothing.
+//
+// Range locks are advisory: the reads and writes of the SafeTreap ignore them.
+// They let callers serialize conflicting range operations, e.g. a range
+// delete and a scan of the same keys, while operations on disjoint ranges
+// carry on concurrently.  They are not reentrant, so locking a range
+// overlapping one held by the same goroutine deadlocks.
+func (s *SafeTreap) LockRange(lo, hi interface{}) (unlock func()) {
+	if !s.atMost(lo, hi) {
+		return func() {}
+	}
+	l, r := &s.ranges, &keyRange{lo: lo, hi: hi}
+
+	l.mu.Lock()
+	if l.released == nil {
+		l.released = sync.NewCond(&l.mu)
+	}
+	for s.conflicts(r) {
+		l.released.Wait()
+	}
+	l.held = append(l.held, r)
+	l.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			for i, h := range l.held {
+				if h == r {
+					l.held = append(l.held[:i], l.held[i+1:]...)
+					break
+				}
+			}
+			l.released.Broadcast()
+		})
+	}
+}
+
+// conflicts reports whether the non-empty range r overlaps a locked range.
+func (s *SafeTreap) conflicts(r *keyRange) bool {
+	for _, h := range s.ranges.held {
+		if s.atMost(r.lo, h.hi) && s.atMost(h.lo, r.hi) {
+			return true
+		}
+	}
+	return false
+}
+
+// atMost reports whether the lower bound lo is not above the upper bound hi.
+func (s *SafeTreap) atMost(lo, hi interface{}) bool {
+	return lo == Unbounded || !s.t.after(lo, hi)
+}
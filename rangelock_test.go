@@ -0,0 +1,37 @@
+package safe_treap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockRange(t *testing.T) {
+	s := NewSafeTreap(NewIntTreap())
+	unlock := s.LockRange(0, 10)
+
+	acquired := make(chan func())
+	go func() {
+		acquired <- s.LockRange(5, Unbounded)
+	}()
+
+	// disjoint and empty ranges do not wait for the held lock.
+	s.LockRange(11, 20)()
+	s.LockRange(Unbounded, -1)()
+	s.LockRange(8, 2)()
+
+	select {
+	case <-acquired:
+		t.Fatal("an overlapping range was locked")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	unlock()
+	unlock() // unlocking twice is harmless
+	select {
+	case other := <-acquired:
+		other()
+	case <-time.After(time.Second):
+		t.Fatal("the overlapping range was not locked after the unlock")
+	}
+	s.LockRange(Unbounded, Unbounded)()
+}
@@ -0,0 +1,78 @@
+package safe_treap
+
+// Every node records the size of its subtree, which the methods below use to
+// answer order-statistics queries along a single path.  The sizes are only
+// maintained for nodes created by the treap; nodes assembled by hand have no
+// size.
+
+// Rank returns the number of keys of n that are less than key, i.e. the
+// position key has or would have in key order.
+//
+// O(log n) if the treap is balanced (see Get).
+func (t *Treap) Rank(n *Node, key interface{}) int {
+	rank := 0
+	for n != nil {
+		switch comp := t.h().CompareKeys(key, n.Key); {
+		case comp < 0:
+			n = n.Left
+		case comp > 0:
+			rank += nodeSize(n.Left) + 1
+			n = n.Right
+		default:
+			return rank + nodeSize(n.Left)
+		}
+	}
+	return rank
+}
+
+// Select returns the entry of n at position k in key order, counting from 0,
+// and false if k is out of range.  Select(n, Len/2) is the median.
+//
+// O(log n) if the treap is balanced (see Get).
+func (t *Treap) Select(n *Node, k int) (interface{}, interface{}, bool) {
+	if n = selectNode(n, k); n == nil {
+		return nil, nil, false
+	}
+	return n.Key, t.value(n.Item), true
+}
+
+// selectNode returns the node at position k of n, or nil.
+func selectNode(n *Node, k int) *Node {
+	if k < 0 {
+		return nil
+	}
+	for n != nil {
+		switch left := nodeSize(n.Left); {
+		case k < left:
+			n = n.Left
+		case k > left:
+			k -= left + 1
+			n = n.Right
+		default:
+			return n
+		}
+	}
+	return nil
+}
+
+// SplitPoints returns the parts-1 keys dividing n into parts ranges of equal
+// size (give or take one), e.g. to draw shard boundaries from the live
+// distribution of the keys: range i holds the keys from point i-1 included to
+// point i excluded.  n is divided into fewer parts if it has fewer keys.
+//
+// O(parts log n) if the treap is balanced (see Get).
+func (t *Treap) SplitPoints(n *Node, parts int) []interface{} {
+	total := nodeSize(n)
+	if parts > total {
+		parts = total
+	}
+	if parts <= 1 {
+		return nil
+	}
+
+	points := make([]interface{}, parts-1)
+	for i := range points {
+		points[i] = selectNode(n, total*(i+1)/parts).Key
+	}
+	return points
+}
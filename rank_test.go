@@ -0,0 +1,74 @@
+package safe_treap
+
+import "testing"
+
+func TestRankSelect(t *testing.T) {
+	tr := NewIntTreap()
+	var root *Node
+	// the even keys 0, 2, ..., 998
+	for k := 0; k < 500; k++ {
+		root, _ = tr.Put(root, 2*k, k)
+	}
+
+	for _, tc := range []struct{ key, rank int }{
+		{-1, 0}, {0, 0}, {1, 1}, {2, 1}, {500, 250}, {998, 499}, {999, 500},
+	} {
+		if got := tr.Rank(root, tc.key); got != tc.rank {
+			t.Errorf("Rank(%d) = %d, want %d", tc.key, got, tc.rank)
+		}
+	}
+
+	for _, k := range []int{0, 1, 250, 499} {
+		key, val, ok := tr.Select(root, k)
+		if !ok || key != 2*k || val != k {
+			t.Errorf("Select(%d) = %v, %v, %v", k, key, val, ok)
+		}
+	}
+	for _, k := range []int{-1, 500} {
+		if _, _, ok := tr.Select(root, k); ok {
+			t.Errorf("Select(%d) found an entry", k)
+		}
+	}
+}
+
+func TestRankAfterUpdates(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 1000)
+	for k := 0; k < 1000; k += 2 {
+		root, _ = tr.Delete(root, k)
+	}
+	root, _ = tr.Put(root, 1000, 0)
+	if err := tr.Validate(root); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := tr.Len(root); got != 501 {
+		t.Errorf("Len = %d, want 501", got)
+	}
+	if got := tr.Rank(root, 501); got != 250 {
+		t.Errorf("Rank(501) = %d, want 250", got)
+	}
+	if key, _, _ := tr.Select(root, 500); key != 1000 {
+		t.Errorf("Select(500) = %v, want 1000", key)
+	}
+}
+
+func TestSplitPoints(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 100)
+
+	points := tr.SplitPoints(root, 4)
+	want := []interface{}{25, 50, 75}
+	if len(points) != len(want) {
+		t.Fatalf("SplitPoints = %v, want %v", points, want)
+	}
+	for i := range want {
+		if points[i] != want[i] {
+			t.Errorf("SplitPoints = %v, want %v", points, want)
+		}
+	}
+
+	if got := tr.SplitPoints(fill(t, tr, 2), 10); len(got) != 1 {
+		t.Errorf("SplitPoints of 2 keys in 10 parts = %v", got)
+	}
+}
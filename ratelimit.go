@@ -0,0 +1,137 @@
+package safe_treap
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenBucket is the state of the rate limit of one identity.
+type TokenBucket struct {
+	Tokens  float64   // tokens left at Updated
+	Updated time.Time // time of the last request
+}
+
+// RateLimitIndex maps identities (API keys, client addresses...) to token
+// buckets refilled at a fixed rate, for API gateways limiting every client on
+// its own.  Buckets idle for the TTL expire: they would be full again anyway,
+// so forgetting them changes no decision.
+//
+// The buckets are kept in a treap keyed by identity and indexed in a second
+// treap by expiry time, so Sweep removes every expired bucket with a single
+// split of the index followed by a batch delete, instead of scanning all of
+// them.  A RateLimitIndex is safe for concurrent use.
+type RateLimitIndex struct {
+	rate  float64 // tokens per second
+	burst float64
+	ttl   time.Duration
+
+	mu       sync.Mutex
+	ids      *Treap
+	expiries *Treap
+	byID     *Node // identity -> TokenBucket
+	byExpiry *Node // expiryKey -> nil
+}
+
+// expiryKey orders buckets by expiry time, then identity.
+type expiryKey struct {
+	at time.Time
+	id string
+}
+
+func compareExpiry(a, b interface{}) int {
+	x, y := a.(expiryKey), b.(expiryKey)
+	if c := x.at.Compare(y.at); c != 0 {
+		return c
+	}
+	return strings.Compare(x.id, y.id)
+}
+
+// NewRateLimitIndex creates an index of buckets holding up to burst tokens and
+// refilled with rate tokens per second, which expire once idle for ttl, or
+// once full again if ttl is 0.
+func NewRateLimitIndex(rate float64, burst int, ttl time.Duration) *RateLimitIndex {
+	if ttl <= 0 && rate > 0 {
+		ttl = time.Duration(float64(burst) / rate * float64(time.Second))
+	}
+	expiries, _ := NewTreap(&Handle{CompareKeys: compareExpiry, CompareWeights: IntComparator})
+	return &RateLimitIndex{
+		rate:     rate,
+		burst:    float64(burst),
+		ttl:      ttl,
+		ids:      NewStringTreap(),
+		expiries: expiries,
+	}
+}
+
+// Allow takes a token from the bucket of id at time now, reporting whether
+// there was one.
+func (r *RateLimitIndex) Allow(id string, now time.Time) bool {
+	return r.AllowN(id, now, 1)
+}
+
+// AllowN takes n tokens from the bucket of id at time now, reporting whether
+// there were that many.  A refused request takes nothing but still counts as
+// activity, delaying the expiry of the bucket.
+func (r *RateLimitIndex) AllowN(id string, now time.Time, n int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := TokenBucket{Tokens: r.burst, Updated: now}
+	if old, ok := r.ids.Get(r.byID, id); ok {
+		b = old.(TokenBucket)
+		r.byExpiry, _ = r.expiries.Delete(r.byExpiry, expiryKey{b.Updated.Add(r.ttl), id})
+		if elapsed := now.Sub(b.Updated).Seconds(); elapsed > 0 {
+			b.Tokens = math.Min(r.burst, b.Tokens+elapsed*r.rate)
+			b.Updated = now
+		}
+	}
+
+	allowed := b.Tokens >= float64(n)
+	if allowed {
+		b.Tokens -= float64(n)
+	}
+	r.byID, _ = r.ids.Put(r.byID, id, b)
+	r.byExpiry, _ = r.expiries.Put(r.byExpiry, expiryKey{b.Updated.Add(r.ttl), id}, nil)
+	return allowed
+}
+
+// Bucket returns the state of the bucket of id as of its last request, and
+// false if id has none.
+func (r *RateLimitIndex) Bucket(id string) (TokenBucket, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.ids.Get(r.byID, id)
+	if !ok {
+		return TokenBucket{}, false
+	}
+	return b.(TokenBucket), true
+}
+
+// Len returns the number of buckets.
+func (r *RateLimitIndex) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return nodeSize(r.byID)
+}
+
+// Sweep removes the buckets expired at time now and returns how many.
+//
+// O(k log n) for k expired buckets.
+func (r *RateLimitIndex) Sweep(now time.Time) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the buckets expiring at or before now sort before this key.
+	expired, rest := r.expiries.Split(r.byExpiry, expiryKey{now.Add(1), ""})
+	var ids []interface{}
+	ascend(expired, func(n *Node) bool {
+		ids = append(ids, n.Key.(expiryKey).id)
+		return true
+	})
+	r.byExpiry = rest
+	var removed int
+	r.byID, removed = r.ids.DeleteBatch(r.byID, ids)
+	return removed
+}
@@ -0,0 +1,79 @@
+package safe_treap
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRateLimitIndex(t *testing.T) {
+	r := NewRateLimitIndex(1, 3, time.Minute)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		if !r.Allow("a", now) {
+			t.Fatalf("request %d of the burst was refused", i)
+		}
+	}
+	if r.Allow("a", now) {
+		t.Error("a request past the burst was allowed")
+	}
+	if !r.Allow("b", now) {
+		t.Error("the bucket of another identity was shared")
+	}
+
+	// one token per second.
+	now = now.Add(1500 * time.Millisecond)
+	if !r.Allow("a", now) || r.Allow("a", now) {
+		t.Error("the bucket did not refill at the rate")
+	}
+	if b, _ := r.Bucket("a"); b.Tokens != 0.5 || !b.Updated.Equal(now) {
+		t.Errorf("bucket = %+v", b)
+	}
+	if r.AllowN("b", now, 5) {
+		t.Error("AllowN took more than the burst")
+	}
+	if _, ok := r.Bucket("c"); ok {
+		t.Error("a bucket for an identity never seen")
+	}
+}
+
+func TestRateLimitIndexSweep(t *testing.T) {
+	r := NewRateLimitIndex(1, 10, time.Minute)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 100; i++ {
+		r.Allow(fmt.Sprint(i), start.Add(time.Duration(i)*time.Second))
+	}
+	// activity postpones the expiry of 5.
+	r.Allow("5", start.Add(90*time.Second))
+
+	if n := r.Sweep(start.Add(59 * time.Second)); n != 0 {
+		t.Errorf("swept %d buckets before any expired", n)
+	}
+	if n := r.Sweep(start.Add(time.Minute + 20*time.Second)); n != 20 || r.Len() != 80 {
+		t.Errorf("swept %d buckets, %d left", n, r.Len())
+	}
+	if _, ok := r.Bucket("5"); !ok {
+		t.Error("an active bucket expired")
+	}
+	if _, ok := r.Bucket("4"); ok {
+		t.Error("an expired bucket is still there")
+	}
+
+	// an expired identity starts over with a full bucket.
+	if !r.AllowN("4", start.Add(2*time.Minute), 10) {
+		t.Error("a swept identity did not get a full bucket")
+	}
+	if n := r.Sweep(start.Add(time.Hour)); n != 81 || r.Len() != 0 {
+		t.Errorf("swept %d buckets, %d left", n, r.Len())
+	}
+}
+
+func TestRateLimitIndexDefaultTTL(t *testing.T) {
+	r := NewRateLimitIndex(2, 10, 0)
+	now := time.Now()
+	r.Allow("a", now)
+	if r.Sweep(now.Add(4*time.Second)) != 0 || r.Sweep(now.Add(5*time.Second)) != 1 {
+		t.Error("a bucket did not expire once full again")
+	}
+}
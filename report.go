@@ -0,0 +1,72 @@
+package safe_treap
+
+// OpReport describes the work done by the mutations measured by Measure.
+type OpReport struct {
+	// Allocated is the number of nodes allocated, including intermediate
+	// copies discarded before the operation returned.
+	Allocated int
+	// Shared is the number of nodes of the result that were taken over from
+	// earlier versions instead of being allocated.
+	Shared int
+	// Rotations is the number of rotations performed.
+	Rotations int
+	// Depth is the depth of the deepest allocated node of the result, which
+	// for single-key operations is the length of the copied search path.
+	Depth int
+}
+
+// opCounters instruments the copy of a Treap used by Measure.
+type opCounters struct {
+	allocations int
+	allocated   map[*Node]struct{} // nil if not needed
+	rotations   int
+
+	budget int // allocations allowed before exhausted, if > 0
+}
+
+// exhausted reports whether the operation allocated more nodes than its
+// budget allows.
+func (t *Treap) exhausted() bool {
+	return t.counters != nil && t.counters.budget > 0 && t.counters.allocations > t.counters.budget
+}
+
+// Measure runs op with an instrumented copy of t and reports the work done by
+// the mutations op performs through it to build the root it returns, for
+// investigating performance without adding counters to the package.  The
+// copy is private to op, so measuring does not slow down or race with other
+// users of t.  It starts with the current root of t (see LoadRoot), but roots
+// op publishes on it are not published on t.
+//
+// The report walks the whole result, so Measure costs O(n) on top of op.
+func (t *Treap) Measure(op func(t *Treap) *Node) OpReport {
+	c := &Treap{config: t.config}
+	c.root.Store(t.root.Load())
+	c.counters = &opCounters{allocated: make(map[*Node]struct{})}
+	root := op(c)
+
+	r := OpReport{
+		Allocated: c.counters.allocations,
+		Rotations: c.counters.rotations,
+	}
+	var walk func(n *Node, depth int)
+	walk = func(n *Node, depth int) {
+		if n == nil {
+			return
+		}
+		if _, ok := c.counters.allocated[n]; !ok {
+			r.Shared++
+		} else if depth > r.Depth {
+			r.Depth = depth
+		}
+		walk(n.Left, depth+1)
+		walk(n.Right, depth+1)
+	}
+	walk(root, 1)
+	return r
+}
+
+func (t *Treap) countRotation() {
+	if t.counters != nil {
+		t.counters.rotations++
+	}
+}
@@ -0,0 +1,29 @@
+package safe_treap
+
+import "testing"
+
+func TestMeasure(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 1000)
+	if !tr.CompareAndSwapRoot(nil, root) {
+		t.Fatal("CompareAndSwapRoot failed")
+	}
+
+	r := tr.Measure(func(c *Treap) *Node {
+		res, _ := c.Put(c.LoadRoot(), 500, "changed")
+		return res
+	})
+	if r.Allocated == 0 || r.Depth == 0 || r.Depth > r.Allocated {
+		t.Errorf("Put reported %+v", r)
+	}
+	if r.Shared+r.Depth != 1000 {
+		t.Errorf("%d shared nodes and a path of %d for a treap of 1000 keys", r.Shared, r.Depth)
+	}
+	if tr.LoadRoot() != root {
+		t.Error("Measure published a root on the measured treap")
+	}
+
+	if r := tr.Measure(func(c *Treap) *Node { return c.LoadRoot() }); r.Allocated != 0 || r.Shared != 1000 {
+		t.Errorf("a read-only op reported %+v", r)
+	}
+}
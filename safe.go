@@ -0,0 +1,153 @@
+package safe_treap
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrWritesFrozen is the misuse of writing to a SafeTreap frozen with
+// FreezeWrites (see MisusePolicy).
+var ErrWritesFrozen = errors.New("writes are frozen")
+
+// SafeTreap shares a single treap between goroutines without external
+// locking.  Reads load the current root and never block; writes build a new
+// root from the current one and publish it with a compare-and-swap, retrying
+// on the new current root if another writer got there first.  The nodes built
+// by a failed attempt are garbage.
+//
+// Writers contending on the same SafeTreap redo their work on every retry, so
+// heavily written treaps are better served by a mutex around Transient or
+// Apply batches.  LockRange serializes operations on overlapping key ranges
+// only.
+//
+// Writes fail with ErrWritesFrozen while the treap is frozen (see
+// FreezeWrites), and like the checked writes of the Treap (see
+// InsertChecked) for entries over its size or depth limits.
+type SafeTreap struct {
+	t    *Treap
+	root atomic.Pointer[Node]
+
+	mu     sync.RWMutex // held shared by writes, exclusively by freezes
+	frozen bool
+
+	ranges  rangeLocks
+	onWrite atomic.Pointer[func()] // set by a Checkpointer
+}
+
+// NewSafeTreap creates an empty SafeTreap ordered by t.
+func NewSafeTreap(t *Treap) *SafeTreap {
+	return &SafeTreap{t: t}
+}
+
+// Root returns the current root, a snapshot unaffected by later writes that
+// may be passed to any method of the Treap.
+func (s *SafeTreap) Root() *Node {
+	return s.root.Load()
+}
+
+// Get returns the item of key in the current root.
+func (s *SafeTreap) Get(key interface{}) (interface{}, bool) {
+	return s.t.Get(s.root.Load(), key)
+}
+
+// Len returns the number of keys of the current root.
+func (s *SafeTreap) Len() int {
+	return nodeSize(s.root.Load())
+}
+
+// Update atomically replaces the root with fn(root), calling fn again on the
+// new root if another writer published one in the meantime, and returns the
+// root it published, or ErrWritesFrozen.  fn must return its argument to
+// leave the treap unchanged, and must not have side effects since it may run
+// several times.
+func (s *SafeTreap) Update(fn func(root *Node) *Node) (*Node, error) {
+	return s.update(func(n *Node) (*Node, error) {
+		return fn(n), nil
+	})
+}
+
+// update is Update for a fn that may fail, in which case nothing is
+// published and its error is returned.
+func (s *SafeTreap) update(fn func(root *Node) (*Node, error)) (*Node, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.frozen {
+		return nil, s.t.misuse(ErrWritesFrozen)
+	}
+
+	for {
+		old := s.root.Load()
+		res, err := fn(old)
+		if err != nil {
+			return nil, err
+		}
+		if res == old {
+			return res, nil
+		}
+		if s.root.CompareAndSwap(old, res) {
+			if fn := s.onWrite.Load(); fn != nil {
+				(*fn)()
+			}
+			return res, nil
+		}
+	}
+}
+
+// FreezeWrites makes every later write fail with ErrWritesFrozen, waits for
+// the writes in flight to complete and returns the final root, e.g. to hand
+// the treap over to another process.  Reads carry on meanwhile.
+func (s *SafeTreap) FreezeWrites() *Node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.frozen = true
+	return s.root.Load()
+}
+
+// Unfreeze accepts writes again, for instance after a handoff was aborted.
+func (s *SafeTreap) Unfreeze() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.frozen = false
+}
+
+// Insert adds key, returning false if it is already present.
+func (s *SafeTreap) Insert(key, val interface{}, weight int) (bool, error) {
+	var ok bool
+	_, err := s.update(func(n *Node) (res *Node, err error) {
+		res, ok, err = s.t.InsertChecked(n, key, val, weight)
+		return res, err
+	})
+	return ok, err
+}
+
+// Upsert inserts key or replaces its item and weight, returning true if key
+// was created.
+func (s *SafeTreap) Upsert(key, val interface{}, weight int) (bool, error) {
+	var created bool
+	_, err := s.update(func(n *Node) (res *Node, err error) {
+		res, created, err = s.t.UpsertChecked(n, key, val, weight)
+		return res, err
+	})
+	return created, err
+}
+
+// Put inserts key or replaces its item, returning true if key was created.
+func (s *SafeTreap) Put(key, val interface{}) (bool, error) {
+	var created bool
+	_, err := s.update(func(n *Node) (res *Node, err error) {
+		res, created, err = s.t.PutChecked(n, key, val)
+		return res, err
+	})
+	return created, err
+}
+
+// Delete removes key, returning false if it was not present.
+func (s *SafeTreap) Delete(key interface{}) (bool, error) {
+	var ok bool
+	_, err := s.update(func(n *Node) (res *Node, err error) {
+		res, ok, err = s.t.DeleteChecked(n, key)
+		return res, err
+	})
+	return ok, err
+}
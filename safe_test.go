@@ -0,0 +1,131 @@
+package safe_treap
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSafeTreap(t *testing.T) {
+	s := NewSafeTreap(NewIntTreap())
+	if created, err := s.Put(1, "a"); !created || err != nil {
+		t.Fatalf("Put = %v, %v", created, err)
+	}
+	snapshot := s.Root()
+
+	if ok, _ := s.Insert(1, "b", 0); ok {
+		t.Error("Insert replaced an existing key")
+	}
+	if created, _ := s.Upsert(1, "c", 0); created {
+		t.Error("Upsert of an existing key reported it created")
+	}
+	if v, _ := s.Get(1); v != "c" {
+		t.Errorf("Get(1) = %v", v)
+	}
+	if v, _ := s.t.Get(snapshot, 1); v != "a" {
+		t.Errorf("the snapshot reads %v", v)
+	}
+	if ok, _ := s.Delete(1); !ok {
+		t.Error("Delete did not find its key")
+	}
+	if ok, _ := s.Delete(1); ok {
+		t.Error("Delete found a deleted key")
+	}
+
+	root := s.Root()
+	if res, err := s.Update(func(n *Node) *Node { return n }); res != root || err != nil {
+		t.Errorf("an Update changing nothing returned %p, %v", res, err)
+	}
+}
+
+func TestSafeTreapConcurrentWrites(t *testing.T) {
+	s := NewSafeTreap(NewIntTreap())
+	const writers, keys = 8, 200
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < keys; k++ {
+				s.Update(func(n *Node) *Node {
+					v, _ := s.t.Get(n, k)
+					count, _ := v.(int)
+					res, _ := s.t.Put(n, k, count+1)
+					return res
+				})
+				s.Get(k)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if s.Len() != keys {
+		t.Fatalf("Len = %d", s.Len())
+	}
+	for k := 0; k < keys; k++ {
+		if v, _ := s.Get(k); v != writers {
+			t.Errorf("key %d was incremented %v times, want %d", k, v, writers)
+		}
+	}
+}
+
+func TestFreezeWrites(t *testing.T) {
+	s := NewSafeTreap(NewIntTreap())
+	s.Put(1, "a")
+	root := s.FreezeWrites()
+	if root != s.Root() {
+		t.Error("FreezeWrites did not return the current root")
+	}
+
+	if _, err := s.Put(2, "b"); err != ErrWritesFrozen {
+		t.Errorf("Put while frozen: %v", err)
+	}
+	if _, err := s.Delete(1); err != ErrWritesFrozen {
+		t.Errorf("Delete while frozen: %v", err)
+	}
+	if res, err := s.Update(func(n *Node) *Node { return nil }); res != nil || err != ErrWritesFrozen {
+		t.Errorf("Update while frozen: %v", err)
+	}
+	if v, ok := s.Get(1); !ok || v != "a" || s.Root() != root {
+		t.Error("a frozen treap changed")
+	}
+
+	s.Unfreeze()
+	if created, err := s.Put(2, "b"); !created || err != nil {
+		t.Errorf("Put after Unfreeze = %v, %v", created, err)
+	}
+}
+
+func TestFreezeWritesPanics(t *testing.T) {
+	tr, err := NewTreap(&Handle{CompareKeys: IntComparator, CompareWeights: IntComparator}, WithMisusePolicy(PanicOnMisuse))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSafeTreap(tr)
+	s.FreezeWrites()
+	defer func() {
+		if r := recover(); r != ErrWritesFrozen {
+			t.Errorf("recovered %v", r)
+		}
+	}()
+	s.Insert(1, "a", 0)
+	t.Error("a write to a frozen treap did not panic")
+}
+
+func TestFreezeWritesDrains(t *testing.T) {
+	s := NewSafeTreap(NewIntTreap())
+	started, release := make(chan struct{}), make(chan struct{})
+	go s.Update(func(n *Node) *Node {
+		close(started)
+		<-release
+		res, _ := s.t.Put(n, 1, "in flight")
+		return res
+	})
+	<-started
+
+	frozen := make(chan *Node)
+	go func() { frozen <- s.FreezeWrites() }()
+	close(release)
+	if root := <-frozen; nodeSize(root) != 1 {
+		t.Error("FreezeWrites returned before the write in flight completed")
+	}
+}
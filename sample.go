@@ -0,0 +1,26 @@
+package safe_treap
+
+import "math/rand"
+
+// SampleReservoir returns a uniform random sample of k entries of n, or all of
+// them if n has fewer, drawing from rng.  The sample is taken in a single
+// ordered pass with O(k) memory (reservoir sampling), and is in no particular
+// order.
+func (t *Treap) SampleReservoir(n *Node, k int, rng *rand.Rand) []KV {
+	if k <= 0 {
+		return nil
+	}
+
+	sample := make([]KV, 0, min(k, nodeSize(n)))
+	seen := 0
+	ascend(n, func(n *Node) bool {
+		seen++
+		if len(sample) < k {
+			sample = append(sample, KV{Key: n.Key, Value: t.value(n.Item)})
+		} else if j := rng.Intn(seen); j < k {
+			sample[j] = KV{Key: n.Key, Value: t.value(n.Item)}
+		}
+		return true
+	})
+	return sample
+}
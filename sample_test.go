@@ -0,0 +1,32 @@
+package safe_treap
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSampleReservoir(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 100)
+	rng := rand.New(rand.NewSource(1))
+
+	sample := tr.SampleReservoir(root, 10, rng)
+	if len(sample) != 10 {
+		t.Fatalf("sample of %d entries, want 10", len(sample))
+	}
+	seen := map[interface{}]bool{}
+	for _, kv := range sample {
+		if v, _ := tr.Get(root, kv.Key); seen[kv.Key] || v != kv.Value {
+			t.Errorf("sampled %v = %v twice or with the wrong value", kv.Key, kv.Value)
+		}
+		seen[kv.Key] = true
+	}
+
+	if got := tr.SampleReservoir(root, math.MaxInt, rng); len(got) != 100 {
+		t.Errorf("sample larger than the treap has %d entries", len(got))
+	}
+	if got := tr.SampleReservoir(root, 0, rng); got != nil {
+		t.Errorf("empty sample = %v", got)
+	}
+}
@@ -0,0 +1,84 @@
+package safe_treap
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrStaleScan is returned by ResumeScan when the snapshot a scan token was
+// saved against is gone or no longer holds the same entries.
+var ErrStaleScan = errors.New("scan token does not match its snapshot")
+
+// Scan walks the entries of a named snapshot (see Snapshot) in key order, one
+// call to Next at a time, and can be saved to a token and resumed later, in
+// another process, as long as the same snapshot is available under the same
+// name there, e.g. restored from a Bundle.  Snapshots are immutable, so a
+// resumed scan returns exactly the entries the interrupted one had left.
+type Scan struct {
+	t    *Treap
+	name string
+	root *Node
+	pos  int         // number of entries returned
+	last interface{} // key of the last of them
+}
+
+// NewScan starts a scan of the snapshot name, returning false if there is no
+// such snapshot.
+func (t *Treap) NewScan(name string) (*Scan, bool) {
+	root, ok := t.SnapshotRoot(name)
+	if !ok {
+		return nil, false
+	}
+	return &Scan{t: t, name: name, root: root}, true
+}
+
+// Next returns the next entry, or false once the scan is complete.
+//
+// O(log n) if the treap is balanced (see Get).
+func (s *Scan) Next() (key, val interface{}, ok bool) {
+	n := selectNode(s.root, s.pos)
+	if n == nil {
+		return nil, nil, false
+	}
+	s.pos++
+	s.last = n.Key
+	return n.Key, s.t.value(n.Item), true
+}
+
+// Save returns a token recording the progress of the scan: the name of the
+// snapshot, the number of entries returned and the last key, encoded with
+// codec.
+func (s *Scan) Save(codec Codec) ([]byte, error) {
+	var buf bytes.Buffer
+	rec := Record{Key: s.last, Item: s.name, Weight: s.pos}
+	if err := codec.NewEncoder(&buf).Encode(&rec); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ResumeScan resumes the scan saved to token with codec, right after the last
+// entry it returned.  It fails with ErrStaleScan if the snapshot is missing or
+// its entry at the saved position is not the last key returned.
+func (t *Treap) ResumeScan(token []byte, codec Codec) (*Scan, error) {
+	var rec Record
+	if err := codec.NewDecoder(bytes.NewReader(token)).Decode(&rec); err != nil {
+		return nil, err
+	}
+	name, ok := rec.Item.(string)
+	if !ok || rec.Weight < 0 {
+		return nil, ErrStaleScan
+	}
+	s, ok := t.NewScan(name)
+	if !ok {
+		return nil, ErrStaleScan
+	}
+	if rec.Weight > 0 {
+		n := selectNode(s.root, rec.Weight-1)
+		if n == nil || t.h().CompareKeys(n.Key, rec.Key) != 0 {
+			return nil, ErrStaleScan
+		}
+	}
+	s.pos, s.last = rec.Weight, rec.Key
+	return s, nil
+}
@@ -0,0 +1,81 @@
+package safe_treap
+
+import "testing"
+
+func TestScan(t *testing.T) {
+	tr := NewIntTreap()
+	tr.CompareAndSwapRoot(nil, fill(t, tr, 100))
+	tr.Snapshot("backup")
+	if _, ok := tr.NewScan("missing"); ok {
+		t.Error("scan of a missing snapshot")
+	}
+
+	s, _ := tr.NewScan("backup")
+	fresh, err := s.Save(GobCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 40; i++ {
+		if k, v, ok := s.Next(); !ok || k != i || v != i*10 {
+			t.Fatalf("Next = %v, %v, %v, want %d", k, v, ok, i)
+		}
+	}
+	token, err := s.Save(GobCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a later process holding the same snapshot, with other writes since.
+	other := NewIntTreap()
+	other.CompareAndSwapRoot(nil, fill(t, other, 100))
+	other.Snapshot("backup")
+	other.CompareAndSwapRoot(other.LoadRoot(), nil)
+
+	resumed, err := other.ResumeScan(token, GobCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 40; i < 100; i++ {
+		if k, _, ok := resumed.Next(); !ok || k != i {
+			t.Fatalf("resumed Next = %v, %v, want %d", k, ok, i)
+		}
+	}
+	if _, _, ok := resumed.Next(); ok {
+		t.Error("the scan went past the last entry")
+	}
+
+	s, err = other.ResumeScan(fresh, GobCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k, _, _ := s.Next(); k != 0 {
+		t.Errorf("a scan resumed before its first entry starts at %v", k)
+	}
+}
+
+func TestResumeScanStale(t *testing.T) {
+	tr := NewIntTreap()
+	tr.CompareAndSwapRoot(nil, fill(t, tr, 100))
+	tr.Snapshot("backup")
+	s, _ := tr.NewScan("backup")
+	for i := 0; i < 10; i++ {
+		s.Next()
+	}
+	token, _ := s.Save(GobCodec)
+
+	// the snapshot now lacks a key before the saved position.
+	root, _ := tr.Delete(tr.LoadRoot(), 3)
+	tr.CompareAndSwapRoot(tr.LoadRoot(), root)
+	tr.Snapshot("backup")
+	if _, err := tr.ResumeScan(token, GobCodec); err != ErrStaleScan {
+		t.Errorf("ResumeScan against another snapshot: %v", err)
+	}
+
+	tr.DropSnapshot("backup")
+	if _, err := tr.ResumeScan(token, GobCodec); err != ErrStaleScan {
+		t.Errorf("ResumeScan without its snapshot: %v", err)
+	}
+	if _, err := tr.ResumeScan([]byte("garbage"), GobCodec); err == nil {
+		t.Error("ResumeScan accepted a garbage token")
+	}
+}
@@ -0,0 +1,70 @@
+package safe_treap
+
+import "sort"
+
+// Sequenced is the item stored by FromOrderedPairsPreservingOrder: the value
+// of a pair along with its position in the original order.
+type Sequenced struct {
+	Seq   uint64
+	Value interface{}
+}
+
+// FromOrderedPairsPreservingOrder builds a treap ordered by the keys of
+// pairs, recording the position of every pair in pairs so that InsertionOrder
+// restores the original order.  This makes migrating from insertion-ordered
+// structures such as ordered maps or LRU lists lossless.
+//
+// Keys must be distinct; a duplicate yields a *KeyExistsError.  A pair
+// failing the limits of t yields the error of InsertStrict.
+func (t *Treap) FromOrderedPairsPreservingOrder(pairs []KV) (*Node, error) {
+	var n *Node
+	for i, p := range pairs {
+		var err error
+		if n, err = t.InsertStrict(n, p.Key, Sequenced{Seq: uint64(i), Value: p.Value}, t.randomWeight()); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// InsertionOrder returns the pairs of n in the order of the sequence numbers
+// of their Sequenced items, unwrapping the values.  An item that is not
+// Sequenced yields a *TypeError.
+//
+// Keys added after the migration keep their place in the original order if
+// they are given a Sequenced item with a new sequence number.
+func (t *Treap) InsertionOrder(n *Node) ([]KV, error) {
+	var (
+		seqs  []uint64
+		pairs []KV
+		err   error
+	)
+	ascend(n, func(n *Node) bool {
+		s, ok := t.value(n.Item).(Sequenced)
+		if !ok {
+			err = &TypeError{Key: n.Key, Value: n.Item, Want: "Sequenced"}
+			return false
+		}
+		seqs = append(seqs, s.Seq)
+		pairs = append(pairs, KV{Key: n.Key, Value: s.Value})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(bySeq{seqs, pairs})
+	return pairs, nil
+}
+
+type bySeq struct {
+	seqs  []uint64
+	pairs []KV
+}
+
+func (s bySeq) Len() int           { return len(s.seqs) }
+func (s bySeq) Less(i, j int) bool { return s.seqs[i] < s.seqs[j] }
+func (s bySeq) Swap(i, j int) {
+	s.seqs[i], s.seqs[j] = s.seqs[j], s.seqs[i]
+	s.pairs[i], s.pairs[j] = s.pairs[j], s.pairs[i]
+}
@@ -0,0 +1,50 @@
+package safe_treap
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPreservingOrder(t *testing.T) {
+	tr := NewStringTreap()
+	pairs := []KV{{"c", 1}, {"a", 2}, {"d", 3}, {"b", 4}}
+	root, err := tr.FromOrderedPairsPreservingOrder(pairs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k, _, _ := tr.Select(root, 0); k != "a" {
+		t.Errorf("smallest key = %v, want a", k)
+	}
+
+	got, err := tr.InsertionOrder(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range pairs {
+		if got[i] != pairs[i] {
+			t.Fatalf("InsertionOrder = %v, want %v", got, pairs)
+		}
+	}
+
+	plain, _ := tr.Put(root, "e", 5)
+	var te *TypeError
+	if _, err := tr.InsertionOrder(plain); !errors.As(err, &te) || te.Key != "e" {
+		t.Errorf("InsertionOrder of a plain item: %v", err)
+	}
+}
+
+func TestPreservingOrderErrors(t *testing.T) {
+	tr := NewStringTreap()
+	var exists *KeyExistsError
+	if _, err := tr.FromOrderedPairsPreservingOrder([]KV{{"a", 1}, {"b", 2}, {"a", 3}}); !errors.As(err, &exists) || exists.Key != "a" {
+		t.Errorf("duplicate key: %v", err)
+	}
+
+	limited, err := NewTreap(&Handle{CompareKeys: StringComparator, CompareWeights: IntComparator}, WithMaxKeySize(2, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := limited.FromOrderedPairsPreservingOrder([]KV{{"a", 1}, {"long", 2}}); !errors.Is(err, ErrKeyTooLarge) {
+		t.Errorf("oversized key: %v", err)
+	}
+}
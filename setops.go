@@ -0,0 +1,151 @@
+package safe_treap
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The set operations take two treaps of the same Treap and return a new root,
+// leaving both operands unchanged.  They split the operand whose root ranks
+// second by the key of the other root and recurse on both halves, which costs
+// O(m log(n/m)) for operands of sizes m <= n when balanced.  Subtrees of a
+// that come out unchanged are shared with the result.
+//
+// A key present in both operands keeps the item and Meta of a, and whichever
+// of its two weights ranks first.
+
+// ErrBudgetExceeded is matched by the errors of the operations that ran out
+// of their node budget.
+var ErrBudgetExceeded = errors.New("node budget exceeded")
+
+// BudgetError reports an operation aborted after allocating more than Max
+// nodes.
+type BudgetError struct {
+	Allocated, Max int
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("allocated %d nodes, over the budget of %d", e.Allocated, e.Max)
+}
+
+// Is makes errors.Is(err, ErrBudgetExceeded) hold.
+func (e *BudgetError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// Union returns the keys present in a or b.
+func (t *Treap) Union(a, b *Node) *Node {
+	return t.merge(a, b, nil)
+}
+
+// Merge is like Union, but the item of a key present in both a and b is
+// resolve(key, item in a, item in b), e.g. to combine per-shard indexes.
+func (t *Treap) Merge(a, b *Node, resolve func(k, va, vb interface{}) interface{}) *Node {
+	return t.merge(a, b, resolve)
+}
+
+// merge unions a and b, resolving the duplicates with resolve if not nil.
+func (t *Treap) merge(a, b *Node, resolve func(k, va, vb interface{}) interface{}) *Node {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case t.exhausted():
+		return nil // abandoned by UnionBudget
+	}
+
+	if t.h().CompareWeights(a.Weight, b.Weight) <= 0 {
+		l, found, r := t.split(b, a.Key)
+		left, right := t.merge(a.Left, l, resolve), t.merge(a.Right, r, resolve)
+		if found != nil && resolve != nil {
+			res := t.clone(a, left, right)
+			res.Item = resolve(a.Key, a.Item, found.Item)
+			return res
+		}
+		if left == a.Left && right == a.Right {
+			return a
+		}
+		return t.clone(a, left, right)
+	}
+
+	l, found, r := t.split(a, b.Key)
+	left, right := t.merge(l, b.Left, resolve), t.merge(r, b.Right, resolve)
+	if found == nil {
+		return t.clone(b, left, right)
+	}
+	res := t.clone(found, left, right)
+	res.Weight = b.Weight
+	if resolve != nil {
+		res.Item = resolve(found.Key, found.Item, b.Item)
+	}
+	return res
+}
+
+// UnionBudget is like Union, but gives up as soon as the merge has allocated
+// more than maxNodes nodes, returning a *BudgetError (matching
+// ErrBudgetExceeded) with the number allocated so far, so that
+// latency-sensitive callers can hand huge merges over to a background worker.
+// The nodes allocated before giving up are garbage; a and b are unchanged.  A
+// maxNodes <= 0 disables the limit.
+func (t *Treap) UnionBudget(a, b *Node, maxNodes int) (*Node, error) {
+	c := &Treap{config: t.config}
+	c.counters = &opCounters{budget: maxNodes}
+	res := c.merge(a, b, nil)
+	if c.exhausted() {
+		return nil, &BudgetError{Allocated: c.counters.allocations, Max: maxNodes}
+	}
+	return res, nil
+}
+
+// Intersect returns the keys present in both a and b.
+func (t *Treap) Intersect(a, b *Node) *Node {
+	if a == nil || b == nil {
+		return nil
+	}
+
+	if t.h().CompareWeights(a.Weight, b.Weight) <= 0 {
+		l, found, r := t.split(b, a.Key)
+		left, right := t.Intersect(a.Left, l), t.Intersect(a.Right, r)
+		switch {
+		case found == nil:
+			return t.join(left, right)
+		case left == a.Left && right == a.Right:
+			return a
+		default:
+			return t.clone(a, left, right)
+		}
+	}
+
+	l, found, r := t.split(a, b.Key)
+	left, right := t.Intersect(l, b.Left), t.Intersect(r, b.Right)
+	if found == nil {
+		return t.join(left, right)
+	}
+	res := t.clone(found, left, right)
+	res.Weight = b.Weight
+	return res
+}
+
+// Difference returns the keys of a that are not in b.
+func (t *Treap) Difference(a, b *Node) *Node {
+	if a == nil || b == nil {
+		return a
+	}
+
+	if t.h().CompareWeights(a.Weight, b.Weight) <= 0 {
+		l, found, r := t.split(b, a.Key)
+		left, right := t.Difference(a.Left, l), t.Difference(a.Right, r)
+		switch {
+		case found != nil:
+			return t.join(left, right)
+		case left == a.Left && right == a.Right:
+			return a
+		default:
+			return t.clone(a, left, right)
+		}
+	}
+
+	l, _, r := t.split(a, b.Key)
+	return t.join(t.Difference(l, b.Left), t.Difference(r, b.Right))
+}
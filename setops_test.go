@@ -0,0 +1,146 @@
+package safe_treap
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// treapOf builds a treap of keys with random weights, with item as the item
+// of every key.
+func treapOf(t *testing.T, tr *Treap, item interface{}, keys []int) *Node {
+	t.Helper()
+	var root *Node
+	for _, k := range keys {
+		root, _ = tr.Upsert(root, k, item, rand.Int())
+	}
+	if err := tr.Validate(root); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// multiples returns the multiples of k below n.
+func multiples(k, n int) []int {
+	var keys []int
+	for i := 0; i < n; i += k {
+		keys = append(keys, i)
+	}
+	return keys
+}
+
+// checkKeys fails unless the keys of n are those for which want holds among
+// 0..n-1, each with the item item(key).
+func checkKeys(t *testing.T, tr *Treap, n *Node, max int, want func(k int) bool, item func(k int) interface{}) {
+	t.Helper()
+	if err := tr.Validate(n); err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for k := 0; k < max; k++ {
+		v, ok := tr.Get(n, k)
+		if ok != want(k) {
+			t.Fatalf("key %d present = %v", k, ok)
+		}
+		if ok {
+			count++
+			if v != item(k) {
+				t.Errorf("key %d = %v, want %v", k, v, item(k))
+			}
+		}
+	}
+	if tr.Len(n) != count {
+		t.Errorf("Len = %d, want %d", tr.Len(n), count)
+	}
+}
+
+func TestSetOperations(t *testing.T) {
+	tr := NewIntTreap()
+	a := treapOf(t, tr, "a", multiples(2, 200))
+	b := treapOf(t, tr, "b", multiples(3, 300))
+	inA := func(k int) bool { return k%2 == 0 && k < 200 }
+	inB := func(k int) bool { return k%3 == 0 }
+	itemOf := func(k int) interface{} {
+		if inA(k) {
+			return "a"
+		}
+		return "b"
+	}
+
+	checkKeys(t, tr, tr.Union(a, b), 300, func(k int) bool { return inA(k) || inB(k) }, itemOf)
+	checkKeys(t, tr, tr.Intersect(a, b), 300, func(k int) bool { return inA(k) && inB(k) }, itemOf)
+	checkKeys(t, tr, tr.Difference(a, b), 300, func(k int) bool { return inA(k) && !inB(k) }, itemOf)
+	checkKeys(t, tr, tr.Difference(b, a), 300, func(k int) bool { return inB(k) && !inA(k) }, itemOf)
+
+	// the operands are left unchanged.
+	checkKeys(t, tr, a, 300, inA, itemOf)
+	checkKeys(t, tr, b, 300, inB, func(int) interface{} { return "b" })
+}
+
+func TestSetOperationsSharing(t *testing.T) {
+	tr := NewIntTreap()
+	a := treapOf(t, tr, "a", multiples(1, 100))
+	if tr.Union(a, nil) != a || tr.Union(nil, a) != a || tr.Intersect(a, nil) != nil || tr.Difference(a, nil) != a {
+		t.Error("an operation with an empty treap did not return its operand")
+	}
+	if tr.Union(a, a) != a || tr.Intersect(a, a) != a || tr.Difference(a, a) != nil {
+		t.Error("an operation of a treap with itself copied it")
+	}
+
+	// a union with a derived version shares everything but the changes.
+	b, _ := tr.Put(a, 1000, "b")
+	r := tr.Measure(func(c *Treap) *Node { return c.Union(a, b) })
+	if r.Shared < 50 {
+		t.Errorf("the union shares %d nodes with its operands", r.Shared)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tr := NewIntTreap()
+	a := treapOf(t, tr, 1, multiples(2, 100))
+	b := treapOf(t, tr, 10, multiples(5, 100))
+
+	calls := 0
+	res := tr.Merge(a, b, func(k, va, vb interface{}) interface{} {
+		calls++
+		if k.(int)%10 != 0 {
+			t.Errorf("resolve called for %v, which is not in both treaps", k)
+		}
+		return va.(int) + vb.(int)
+	})
+	if calls != 10 {
+		t.Errorf("resolve called %d times, want 10", calls)
+	}
+	checkKeys(t, tr, res, 100, func(k int) bool { return k%2 == 0 || k%5 == 0 }, func(k int) interface{} {
+		switch {
+		case k%10 == 0:
+			return 11
+		case k%2 == 0:
+			return 1
+		}
+		return 10
+	})
+}
+
+func TestUnionBudget(t *testing.T) {
+	tr := NewIntTreap()
+	a := treapOf(t, tr, "a", multiples(2, 2000))
+	b := treapOf(t, tr, "b", multiples(3, 2000))
+
+	_, err := tr.UnionBudget(a, b, 10)
+	var be *BudgetError
+	if !errors.As(err, &be) || !errors.Is(err, ErrBudgetExceeded) || be.Max != 10 || be.Allocated <= 10 {
+		t.Fatalf("UnionBudget over its budget: %v", err)
+	}
+	checkKeys(t, tr, a, 2000, func(k int) bool { return k%2 == 0 }, func(int) interface{} { return "a" })
+
+	for _, max := range []int{0, 1 << 20} {
+		res, err := tr.UnionBudget(a, b, max)
+		if err != nil {
+			t.Fatalf("UnionBudget(%d): %v", max, err)
+		}
+		if added, removed, _ := tr.Diff(tr.Union(a, b), res); len(added)+len(removed) != 0 {
+			t.Errorf("UnionBudget(%d) differs from Union", max)
+		}
+	}
+}
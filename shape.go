@@ -0,0 +1,96 @@
+package safe_treap
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrBadShape is returned by Decode when the records do not describe a valid
+// treap.
+var ErrBadShape = errors.New("records do not form a treap")
+
+// Encode writes the entries of n to w with their weights in pre-order, every
+// node before its left and then its right subtree.  A binary search tree is
+// determined by the pre-order of its keys, so Decode rebuilds exactly the
+// same shape, unlike DecodeSorted which rebuilds the shape implied by the
+// weights.  This matters for treaps whose weights tie.
+//
+// The keys are not in ascending order, so codecs that rely on it, such as
+// DeltaIntCodec, can not be used.
+func (t *Treap) Encode(w io.Writer, n *Node, codec Codec) error {
+	enc := codec.NewEncoder(w)
+	var err error
+	t.WalkCtl(n, func(n *Node) WalkDecision {
+		if err = enc.Encode(&Record{Key: n.Key, Item: n.Item, Weight: n.Weight}); err != nil {
+			return Stop
+		}
+		return Continue
+	})
+	return err
+}
+
+// Decode rebuilds a treap written by Encode in O(n), checking that its keys
+// are ordered by the key comparator of t and its weights by the weight
+// comparator, and returning ErrBadShape otherwise.
+func (t *Treap) Decode(r io.Reader, codec Codec) (*Node, error) {
+	var (
+		dec   = codec.NewDecoder(r)
+		root  *Node
+		path  []*Node // the nodes whose right child is still to come
+		lower *Node   // the node every later key must be greater than
+	)
+
+	for {
+		var rec Record
+		if err := dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		n := t.newNode()
+		n.Weight, n.Key, n.Item = rec.Weight, rec.Key, rec.Item
+		if lower != nil && t.h().CompareKeys(n.Key, lower.Key) <= 0 {
+			return nil, ErrBadShape
+		}
+
+		// n is the right child of the last node of the path it is greater
+		// than, or else the left child of the last node of the path.
+		var parent *Node
+		for len(path) > 0 && t.h().CompareKeys(n.Key, path[len(path)-1].Key) > 0 {
+			parent = path[len(path)-1]
+			path = path[:len(path)-1]
+		}
+		switch {
+		case parent != nil:
+			parent.Right, lower = n, parent
+		case len(path) > 0:
+			parent = path[len(path)-1]
+			if t.h().CompareKeys(n.Key, parent.Key) == 0 {
+				return nil, ErrBadShape
+			}
+			parent.Left = n
+		default:
+			if root != nil {
+				return nil, ErrBadShape
+			}
+			root = n
+		}
+		if parent != nil && t.h().CompareWeights(n.Weight, parent.Weight) < 0 {
+			return nil, ErrBadShape
+		}
+		path = append(path, n)
+	}
+
+	resizeAll(root)
+	return root, nil
+}
+
+// resizeAll computes the sizes of a tree assembled without them.
+func resizeAll(n *Node) int {
+	if n == nil {
+		return 0
+	}
+	n.size = 1 + resizeAll(n.Left) + resizeAll(n.Right)
+	return n.size
+}
@@ -0,0 +1,73 @@
+package safe_treap
+
+import (
+	"bytes"
+	"testing"
+)
+
+// preorder returns the keys of n in pre-order.
+func preorder(tr *Treap, n *Node) []interface{} {
+	var keys []interface{}
+	tr.WalkCtl(n, func(n *Node) WalkDecision {
+		keys = append(keys, n.Key)
+		return Continue
+	})
+	return keys
+}
+
+func TestEncodeDecodeShape(t *testing.T) {
+	tr := NewIntTreap()
+	// tied weights leave the shape to the order of insertion.
+	var root *Node
+	for _, k := range []int{5, 2, 8, 1, 3, 9, 7, 4, 6, 0} {
+		root, _ = tr.Insert(root, k, k*10, 0)
+	}
+
+	var buf bytes.Buffer
+	if err := tr.Encode(&buf, root, GobCodec); err != nil {
+		t.Fatal(err)
+	}
+	got, err := tr.Decode(&buf, GobCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Validate(got); err != nil {
+		t.Fatal(err)
+	}
+	want, have := preorder(tr, root), preorder(tr, got)
+	for i := range want {
+		if want[i] != have[i] {
+			t.Fatalf("decoded pre-order %v, want %v", have, want)
+		}
+	}
+	if v, _ := tr.Get(got, 7); v != 70 {
+		t.Errorf("Get(7) = %v", v)
+	}
+
+	if got, err := tr.Decode(&bytes.Buffer{}, GobCodec); got != nil || err != nil {
+		t.Errorf("Decode of an empty stream = %v, %v", got, err)
+	}
+}
+
+func TestDecodeBadShape(t *testing.T) {
+	tr := NewIntTreap()
+	for _, tc := range []struct {
+		name string
+		recs []Record
+	}{
+		{"duplicate", []Record{{Key: 2}, {Key: 1}, {Key: 1}}},
+		{"unordered", []Record{{Key: 2}, {Key: 3}, {Key: 1}}},
+		{"heap order", []Record{{Key: 2, Weight: 5}, {Key: 1, Weight: 4}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := GobCodec.NewEncoder(&buf)
+			for i := range tc.recs {
+				enc.Encode(&tc.recs[i])
+			}
+			if _, err := tr.Decode(&buf, GobCodec); err != ErrBadShape {
+				t.Errorf("err = %v, want ErrBadShape", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package safe_treap
+
+// SlabAllocator is an Allocator carving nodes out of slabs of contiguous
+// nodes, which takes one heap allocation per slab instead of one per node and
+// keeps nodes created together close in memory.  Freed nodes are reused.
+//
+// A slab stays in memory as long as any of its nodes is reachable from a
+// root, so slabs suit treaps whose versions are dropped together.  A
+// SlabAllocator is not safe for concurrent use; give each writer its own.
+type SlabAllocator struct {
+	size  int
+	slab  []Node
+	next  int
+	freed []*Node
+}
+
+// NewSlabAllocator creates an allocator with slabs of size nodes.
+func NewSlabAllocator(size int) *SlabAllocator {
+	if size < 1 {
+		size = 1
+	}
+	return &SlabAllocator{size: size}
+}
+
+// NewNode implements Allocator.
+func (a *SlabAllocator) NewNode() *Node {
+	if k := len(a.freed); k > 0 {
+		n := a.freed[k-1]
+		a.freed = a.freed[:k-1]
+		*n = Node{}
+		return n
+	}
+	if a.next == len(a.slab) {
+		a.slab, a.next = make([]Node, a.size), 0
+	}
+	n := &a.slab[a.next]
+	a.next++
+	return n
+}
+
+// Free implements Allocator.
+func (a *SlabAllocator) Free(n *Node) {
+	a.freed = append(a.freed, n)
+}
@@ -0,0 +1,28 @@
+package safe_treap
+
+import "testing"
+
+func TestSlabAllocator(t *testing.T) {
+	a := NewSlabAllocator(16)
+	first := a.NewNode()
+	first.Key = 1
+	a.Free(first)
+	if n := a.NewNode(); n != first || n.Key != nil {
+		t.Error("a freed node was not reused zeroed")
+	}
+
+	tr, err := NewTreap(&Handle{CompareKeys: IntComparator, CompareWeights: IntComparator}, WithAllocator(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := fill(t, tr, 1000)
+	for k := 0; k < 1000; k += 2 {
+		root, _ = tr.Delete(root, k)
+	}
+	if err := tr.Validate(root); err != nil {
+		t.Fatal(err)
+	}
+	if tr.Len(root) != 500 {
+		t.Errorf("Len = %d, want 500", tr.Len(root))
+	}
+}
@@ -0,0 +1,129 @@
+package safe_treap
+
+import (
+	"sort"
+	"time"
+)
+
+// snapshot is a root recorded by Snapshot.
+type snapshot struct {
+	root  *Node
+	taken time.Time
+}
+
+// Snapshot records the current root of the treap (see LoadRoot) under name,
+// replacing any snapshot of that name.  Roots are immutable, so a snapshot
+// costs a map entry however large the treap is; its nodes are kept in memory
+// until the snapshot is dropped.  With WithSnapshotRetention, the snapshots
+// the policy no longer keeps are dropped right after.
+func (t *Treap) Snapshot(name string) {
+	t.snapshotMu.Lock()
+	defer t.snapshotMu.Unlock()
+	if t.snapshots == nil {
+		t.snapshots = make(map[string]snapshot)
+	}
+	now := time.Now()
+	t.snapshots[name] = snapshot{root: t.LoadRoot(), taken: now}
+	if t.retention != nil {
+		t.prune(*t.retention, now)
+	}
+}
+
+// Restore sets the root of the treap back to the snapshot name, returning
+// false if there is no such snapshot.  The snapshot is kept.
+func (t *Treap) Restore(name string) bool {
+	n, ok := t.SnapshotRoot(name)
+	if ok {
+		t.root.Store(n)
+	}
+	return ok
+}
+
+// SnapshotRoot returns the root recorded as name, to read a point-in-time
+// view without restoring it.
+func (t *Treap) SnapshotRoot(name string) (*Node, bool) {
+	t.snapshotMu.Lock()
+	defer t.snapshotMu.Unlock()
+	s, ok := t.snapshots[name]
+	return s.root, ok
+}
+
+// DropSnapshot forgets the snapshot name.
+func (t *Treap) DropSnapshot(name string) {
+	t.snapshotMu.Lock()
+	defer t.snapshotMu.Unlock()
+	delete(t.snapshots, name)
+}
+
+// Snapshots returns the names of the snapshots in ascending order.
+func (t *Treap) Snapshots() []string {
+	t.snapshotMu.Lock()
+	defer t.snapshotMu.Unlock()
+	names := make([]string, 0, len(t.snapshots))
+	for name := range t.snapshots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RetentionPolicy decides which snapshots are kept by PruneSnapshots.  A
+// snapshot is kept if either rule keeps it, so the zero policy keeps none.
+type RetentionPolicy struct {
+	// KeepLast is the number of most recent snapshots kept.
+	KeepLast int
+	// Hourly is the age up to which the latest snapshot taken in every hour
+	// is kept, e.g. 24*time.Hour to keep hourly snapshots for a day.
+	Hourly time.Duration
+}
+
+// WithSnapshotRetention makes Snapshot prune the snapshots with p (see
+// PruneSnapshots) every time it records one, so that the history of roots
+// does not grow without bound.
+func WithSnapshotRetention(p RetentionPolicy) Option {
+	return func(t *Treap) {
+		t.retention = &p
+	}
+}
+
+// PruneSnapshots drops the snapshots that p does not keep at time now and
+// returns their names in ascending order.  The nodes only reachable from
+// dropped snapshots are left to the garbage collector.
+func (t *Treap) PruneSnapshots(p RetentionPolicy, now time.Time) []string {
+	t.snapshotMu.Lock()
+	defer t.snapshotMu.Unlock()
+	return t.prune(p, now)
+}
+
+func (t *Treap) prune(p RetentionPolicy, now time.Time) []string {
+	names := make([]string, 0, len(t.snapshots))
+	for name := range t.snapshots {
+		names = append(names, name)
+	}
+	// newest first; names break ties so that the outcome is deterministic.
+	sort.Slice(names, func(i, j int) bool {
+		a, b := t.snapshots[names[i]].taken, t.snapshots[names[j]].taken
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return names[i] > names[j]
+	})
+
+	var dropped []string
+	hours := make(map[time.Time]bool)
+	for i, name := range names {
+		taken := t.snapshots[name].taken
+		keep := i < p.KeepLast
+		if now.Sub(taken) <= p.Hourly {
+			hour := taken.Truncate(time.Hour)
+			keep = keep || !hours[hour]
+			hours[hour] = true
+		}
+		if !keep {
+			delete(t.snapshots, name)
+			dropped = append(dropped, name)
+		}
+	}
+	sort.Strings(dropped)
+	return dropped
+}
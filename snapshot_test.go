@@ -0,0 +1,80 @@
+package safe_treap
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSnapshots(t *testing.T) {
+	tr := NewIntTreap()
+	tr.CompareAndSwapRoot(nil, fill(t, tr, 10))
+	tr.Snapshot("ten")
+	root := tr.LoadRoot()
+	res, _ := tr.Put(root, 10, 100)
+	tr.CompareAndSwapRoot(root, res)
+
+	if n, ok := tr.SnapshotRoot("ten"); !ok || n != root {
+		t.Error("SnapshotRoot did not return the recorded root")
+	}
+	if !tr.Restore("ten") || tr.LoadRoot() != root {
+		t.Error("Restore did not bring the snapshot back")
+	}
+	tr.DropSnapshot("ten")
+	if tr.Restore("ten") || len(tr.Snapshots()) != 0 {
+		t.Error("a dropped snapshot is still restorable")
+	}
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	tr := NewIntTreap()
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	// one snapshot every 20 minutes over two days.
+	for i := 0; i < 144; i++ {
+		tr.Snapshot(fmt.Sprintf("s%03d", i))
+		tr.snapshots[fmt.Sprintf("s%03d", i)] = snapshot{taken: now.Add(-time.Duration(i) * 20 * time.Minute)}
+	}
+
+	dropped := tr.PruneSnapshots(RetentionPolicy{KeepLast: 5, Hourly: 24 * time.Hour}, now)
+	kept := tr.Snapshots()
+	if len(kept)+len(dropped) != 144 {
+		t.Fatalf("kept %d and dropped %d of 144 snapshots", len(kept), len(dropped))
+	}
+	// s000 to s004 are the newest, and the 25 hours from 12:00 yesterday to
+	// 12:59 today each keep their latest snapshot: s000, s002 and s005 among
+	// them.
+	hours := map[time.Time]bool{}
+	for _, name := range kept {
+		taken := tr.snapshots[name].taken
+		if now.Sub(taken) > 24*time.Hour {
+			t.Errorf("%s is %v old", name, now.Sub(taken))
+		}
+		if name > "s004" {
+			if hours[taken.Truncate(time.Hour)] {
+				t.Errorf("%s is a second snapshot for its hour", name)
+			}
+		}
+		hours[taken.Truncate(time.Hour)] = true
+	}
+	if len(kept) != 28 {
+		t.Errorf("kept %d snapshots: %v", len(kept), kept)
+	}
+
+	if dropped := tr.PruneSnapshots(RetentionPolicy{KeepLast: 1}, now); len(dropped) != 27 || tr.Snapshots()[0] != "s000" {
+		t.Errorf("KeepLast 1 dropped %d and kept %v", len(dropped), tr.Snapshots())
+	}
+}
+
+func TestSnapshotRetention(t *testing.T) {
+	tr, err := NewTreap(&Handle{CompareKeys: IntComparator, CompareWeights: IntComparator},
+		WithSnapshotRetention(RetentionPolicy{KeepLast: 3}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		tr.Snapshot(fmt.Sprint(i))
+	}
+	if names := tr.Snapshots(); len(names) != 3 || names[0] != "7" {
+		t.Errorf("kept %v, want the last 3", names)
+	}
+}
@@ -0,0 +1,80 @@
+package safe_treap
+
+// Stats summarizes the memory held by a set of versions of a treap and how
+// much of it they share.
+type Stats struct {
+	// Versions is the number of non-empty roots.
+	Versions int
+	// Nodes is the sum of the sizes of the versions, i.e. the number of nodes
+	// they would hold without structural sharing.
+	Nodes int
+	// Unique is the number of distinct nodes reachable from the roots, and
+	// Shared the number of those reachable from more than one root.
+	Unique, Shared int
+	// Height is the height of the tallest version.
+	Height int
+	// Bytes estimates the memory used by the unique nodes, not counting
+	// keys, items and Meta.
+	Bytes int
+}
+
+// SharingRatio is the number of nodes the versions hold per unique node: 1
+// when they share nothing, and up to Versions when they are identical.
+func (s Stats) SharingRatio() float64 {
+	if s.Unique == 0 {
+		return 0
+	}
+	return float64(s.Nodes) / float64(s.Unique)
+}
+
+// Stats computes the node counts and sharing of the versions roots, e.g. to
+// watch the cost of the snapshots kept alive.  Every distinct node is visited
+// once, so the cost is O(Unique) rather than O(Nodes).
+func (t *Treap) Stats(roots ...*Node) Stats {
+	var (
+		s    Stats
+		seen = make(map[*Node]*nodeStats)
+	)
+
+	// visit returns the height of n, marking as shared the nodes of n that
+	// were reached from a root other than root.
+	var visit func(n *Node, root int) int
+	visit = func(n *Node, root int) int {
+		if n == nil {
+			return 0
+		}
+		if st, ok := seen[n]; ok {
+			// the descendants of a shared node are shared too.
+			if st.root != root && !st.shared {
+				st.shared = true
+				s.Shared++
+				visit(n.Left, root)
+				visit(n.Right, root)
+			}
+			return st.height
+		}
+
+		st := &nodeStats{root: root}
+		seen[n] = st
+		s.Unique++
+		st.height = 1 + max(visit(n.Left, root), visit(n.Right, root))
+		return st.height
+	}
+
+	for i, root := range roots {
+		if root == nil {
+			continue
+		}
+		s.Versions++
+		s.Nodes += nodeSize(root)
+		s.Height = max(s.Height, visit(root, i))
+	}
+	s.Bytes = s.Unique * nodeOverhead
+	return s
+}
+
+type nodeStats struct {
+	root   int // the first root the node was reached from
+	height int
+	shared bool
+}
@@ -0,0 +1,311 @@
+package safe_treap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+var (
+	// ErrChecksum is returned when a snapshot chunk does not match its
+	// checksum.
+	ErrChecksum = errors.New("snapshot chunk checksum mismatch")
+	// ErrChunkOrder is returned when a snapshot chunk is not the one the
+	// receiver expects next.
+	ErrChunkOrder = errors.New("snapshot chunk out of order")
+	// ErrChunkSize is returned when a snapshot chunk is larger than the
+	// chunk size of the transfer.
+	ErrChunkSize = errors.New("snapshot chunk too large")
+)
+
+// defaultChunkSize is the chunk size used when SendOptions.ChunkSize is 0.
+const defaultChunkSize = 64 << 10
+
+// SendOptions configures SendSnapshot.
+type SendOptions struct {
+	// Codec encodes the entries of the snapshot.  Resuming a transfer
+	// relies on the codec producing the same bytes for the same entries.
+	Codec Codec
+	// ChunkSize is the size of the chunks before framing, 64KiB if 0.
+	ChunkSize int
+	// Offset is the number of chunks the receiver already has, as reported
+	// by SnapshotReceiver.Offset; they are not sent again.
+	Offset int
+}
+
+// SendSnapshot streams the entries of n to w as a sequence of numbered and
+// checksummed chunks, to be read by a SnapshotReceiver created with the same
+// chunk size.  Since n is
+// immutable, an interrupted transfer can be resumed on a new connection by
+// sending the same root again with the receiver's offset.
+func (t *Treap) SendSnapshot(w io.Writer, n *Node, opts SendOptions) error {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = defaultChunkSize
+	}
+
+	cw := &chunkWriter{w: bufio.NewWriter(w), size: opts.ChunkSize, skip: opts.Offset}
+	if err := encodeSorted(opts.Codec.NewEncoder(cw), n); err != nil {
+		return err
+	}
+	return cw.close()
+}
+
+// chunkWriter frames the bytes written to it as chunks of
+//
+//	seq (uvarint) | length (uvarint) | crc32 (4 bytes, big endian) | data
+//
+// followed by an end of stream frame with a length of 0.  The first skip
+// chunks are dropped.
+type chunkWriter struct {
+	w    *bufio.Writer
+	buf  []byte
+	size int
+	seq  int
+	skip int
+}
+
+func (c *chunkWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := c.size - len(c.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+		c.buf = append(c.buf, p[:n]...)
+		p = p[n:]
+
+		if len(c.buf) == c.size {
+			if err := c.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return written, nil
+}
+
+func (c *chunkWriter) flush() error {
+	if c.seq >= c.skip {
+		var hdr [2*binary.MaxVarintLen64 + 4]byte
+		i := binary.PutUvarint(hdr[:], uint64(c.seq))
+		i += binary.PutUvarint(hdr[i:], uint64(len(c.buf)))
+		if len(c.buf) > 0 {
+			binary.BigEndian.PutUint32(hdr[i:], crc32.ChecksumIEEE(c.buf))
+			i += 4
+		}
+		c.w.Write(hdr[:i])
+		if _, err := c.w.Write(c.buf); err != nil {
+			return err
+		}
+	}
+	c.seq++
+	c.buf = c.buf[:0]
+	return nil
+}
+
+func (c *chunkWriter) close() error {
+	if len(c.buf) > 0 {
+		if err := c.flush(); err != nil {
+			return err
+		}
+	}
+	// the end of stream frame is always sent, even when resuming past it.
+	if c.skip > c.seq {
+		c.skip = c.seq
+	}
+	if err := c.flush(); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// SnapshotReceiver reassembles a snapshot sent with SendSnapshot, possibly
+// over several connections.  Received chunks are kept in memory until the
+// snapshot is complete.
+type SnapshotReceiver struct {
+	codec     Codec
+	chunkSize int
+	data      bytes.Buffer
+	next      int
+	done      bool
+}
+
+// NewSnapshotReceiver creates a receiver for snapshots encoded with codec and
+// sent in chunks of chunkSize bytes, 64KiB if 0, as set by
+// SendOptions.ChunkSize.
+func NewSnapshotReceiver(codec Codec, chunkSize int) *SnapshotReceiver {
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	return &SnapshotReceiver{codec: codec, chunkSize: chunkSize}
+}
+
+// Offset is the number of chunks received so far, to pass as
+// SendOptions.Offset when resuming the transfer.
+func (s *SnapshotReceiver) Offset() int {
+	return s.next
+}
+
+// Done reports whether the whole snapshot has been received.
+func (s *SnapshotReceiver) Done() bool {
+	return s.done
+}
+
+// Receive reads chunks from r until the end of the snapshot.  Chunks that were
+// verified before an error are kept, so the transfer can be resumed from
+// Offset.  A chunk longer than the chunk size fails with ErrChunkSize before
+// anything is allocated for it.
+func (s *SnapshotReceiver) Receive(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for !s.done {
+		chunk, err := readChunk(br, s.next, s.chunkSize)
+		if err != nil {
+			return err
+		}
+		if chunk == nil {
+			s.done = true
+			break
+		}
+		s.data.Write(chunk)
+		s.next++
+	}
+	return nil
+}
+
+// readChunk reads the frame of chunk seq from br and verifies it, returning
+// nil at the end of the stream.
+func readChunk(br *bufio.Reader, seq, chunkSize int) ([]byte, error) {
+	got, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	size, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	if int(got) != seq {
+		return nil, ErrChunkOrder
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	if size > uint64(chunkSize) {
+		return nil, ErrChunkSize
+	}
+
+	var sum [4]byte
+	if _, err := io.ReadFull(br, sum[:]); err != nil {
+		return nil, unexpected(err)
+	}
+	chunk := make([]byte, size)
+	if _, err := io.ReadFull(br, chunk); err != nil {
+		return nil, unexpected(err)
+	}
+	if crc32.ChecksumIEEE(chunk) != binary.BigEndian.Uint32(sum[:]) {
+		return nil, ErrChecksum
+	}
+	return chunk, nil
+}
+
+// Root decodes the received snapshot with the comparators of t.
+func (s *SnapshotReceiver) Root(t *Treap) (*Node, error) {
+	if !s.done {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return t.DecodeSorted(bytes.NewReader(s.data.Bytes()), s.codec)
+}
+
+// ReceiveSnapshot reads a whole snapshot sent with SendSnapshot from r in one
+// go and decodes it with the comparators of t.  chunkSize is as for
+// NewSnapshotReceiver.
+func (t *Treap) ReceiveSnapshot(r io.Reader, codec Codec, chunkSize int) (*Node, error) {
+	s := NewSnapshotReceiver(codec, chunkSize)
+	if err := s.Receive(r); err != nil {
+		return nil, err
+	}
+	return s.Root(t)
+}
+
+// VerifySnapshot checks a whole snapshot sent with SendSnapshot as it is read
+// from r, without building a treap: every chunk must match its checksum and
+// come in sequence up to the end of stream frame, and the entries decoded with
+// codec must be in strictly ascending key order by the comparator of t, which
+// is all a stream needs to describe a valid treap.  Only one chunk is held in
+// memory at a time.  It returns the number of entries, or the first error
+// found (ErrChecksum, ErrChunkOrder, ErrChunkSize, ErrUnsorted, or an error
+// of the codec).  chunkSize is as for NewSnapshotReceiver.
+//
+// Snapshots carry no Merkle hashes: to check one against a trusted RootHash,
+// receive it and hash the resulting root.
+func (t *Treap) VerifySnapshot(r io.Reader, codec Codec, chunkSize int) (int, error) {
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	cr := &chunkReader{br: bufio.NewReader(r), chunkSize: chunkSize}
+	dec := codec.NewDecoder(cr)
+
+	var (
+		count int
+		last  interface{}
+	)
+	for {
+		var rec Record
+		if err := dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			if cr.err != nil {
+				return count, cr.err
+			}
+			return count, err
+		}
+		if count > 0 && t.h().CompareKeys(rec.Key, last) <= 0 {
+			return count, ErrUnsorted
+		}
+		last = rec.Key
+		count++
+	}
+	// the codec may have stopped short of the end of stream frame.
+	_, err := io.Copy(io.Discard, cr)
+	return count, err
+}
+
+// chunkReader reads the data of a chunked snapshot, verifying every chunk as
+// it is reached.  err is io.EOF after the end of stream frame.
+type chunkReader struct {
+	br        *bufio.Reader
+	chunkSize int
+	seq       int
+	buf       []byte
+	err       error
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		chunk, err := readChunk(c.br, c.seq, c.chunkSize)
+		switch {
+		case err != nil:
+			c.err = err
+		case chunk == nil:
+			c.err = io.EOF
+		default:
+			c.buf = chunk
+			c.seq++
+		}
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+// unexpected turns a clean EOF in the middle of a snapshot into an error.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+package safe_treap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// cutWriter writes up to left bytes to w and fails afterwards, like a
+// connection dropped in the middle of a transfer.
+type cutWriter struct {
+	w    io.Writer
+	left int
+}
+
+func (c *cutWriter) Write(p []byte) (int, error) {
+	if len(p) > c.left {
+		n, _ := c.w.Write(p[:c.left])
+		c.left = 0
+		return n, io.ErrShortWrite
+	}
+	c.left -= len(p)
+	return c.w.Write(p)
+}
+
+func TestSnapshotTransfer(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 2000)
+
+	var buf bytes.Buffer
+	if err := tr.SendSnapshot(&buf, root, SendOptions{Codec: GobCodec, ChunkSize: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := tr.ReceiveSnapshot(&buf, GobCodec, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Validate(got); err != nil {
+		t.Fatal(err)
+	}
+	if added, removed, changed := tr.Diff(root, got); len(added)+len(removed)+len(changed) != 0 {
+		t.Errorf("received treap differs: +%v -%v ~%v", added, removed, changed)
+	}
+}
+
+func TestSnapshotTransferResume(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 2000)
+	opts := SendOptions{Codec: GobCodec, ChunkSize: 1000}
+	r := NewSnapshotReceiver(GobCodec, opts.ChunkSize)
+
+	var first bytes.Buffer
+	tr.SendSnapshot(&cutWriter{w: &first, left: 5000}, root, opts)
+	if err := r.Receive(&first); err != io.ErrUnexpectedEOF {
+		t.Fatalf("interrupted Receive: %v", err)
+	}
+	if r.Done() || r.Offset() == 0 {
+		t.Fatalf("Done = %v, Offset = %d after a partial transfer", r.Done(), r.Offset())
+	}
+
+	var rest bytes.Buffer
+	opts.Offset = r.Offset()
+	if err := tr.SendSnapshot(&rest, root, opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Receive(&rest); err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.Root(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Len(got) != 2000 {
+		t.Errorf("resumed transfer holds %d keys", tr.Len(got))
+	}
+}
+
+func TestSnapshotReceiveMalformed(t *testing.T) {
+	tr := NewIntTreap()
+	var good bytes.Buffer
+	if err := tr.SendSnapshot(&good, fill(t, tr, 500), SendOptions{Codec: GobCodec, ChunkSize: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	flipped := append([]byte(nil), good.Bytes()...)
+	flipped[100] ^= 0xff
+	frame := func(seq, size uint64) []byte {
+		return binary.AppendUvarint(binary.AppendUvarint(nil, seq), size)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		data      []byte
+		chunkSize int
+		want      error
+	}{
+		{"checksum", flipped, 1000, ErrChecksum},
+		{"order", frame(1, 10), 1000, ErrChunkOrder},
+		{"huge chunk", frame(0, 1<<62), 0, ErrChunkSize},
+		{"over chunk size", good.Bytes(), 999, ErrChunkSize},
+		{"truncated", good.Bytes()[:good.Len()-1], 1000, io.ErrUnexpectedEOF},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := tr.ReceiveSnapshot(bytes.NewReader(tc.data), GobCodec, tc.chunkSize); err != tc.want {
+				t.Errorf("err = %v, want %v", err, tc.want)
+			}
+			if _, err := tr.VerifySnapshot(bytes.NewReader(tc.data), GobCodec, tc.chunkSize); err != tc.want {
+				t.Errorf("VerifySnapshot: err = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestVerifySnapshot(t *testing.T) {
+	tr := NewIntTreap()
+	var buf bytes.Buffer
+	if err := tr.SendSnapshot(&buf, fill(t, tr, 2000), SendOptions{Codec: GobCodec, ChunkSize: 1000}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := tr.VerifySnapshot(&buf, GobCodec, 1000); n != 2000 || err != nil {
+		t.Errorf("VerifySnapshot = %d, %v", n, err)
+	}
+
+	buf.Reset()
+	tr.SendSnapshot(&buf, nil, SendOptions{Codec: GobCodec})
+	if n, err := tr.VerifySnapshot(&buf, GobCodec, 0); n != 0 || err != nil {
+		t.Errorf("VerifySnapshot of an empty snapshot = %d, %v", n, err)
+	}
+
+	// well framed, but not in key order.
+	buf.Reset()
+	cw := &chunkWriter{w: bufio.NewWriter(&buf), size: 100}
+	enc := GobCodec.NewEncoder(cw)
+	for _, k := range []int{1, 3, 2} {
+		enc.Encode(&Record{Key: k, Item: k})
+	}
+	cw.close()
+	if n, err := tr.VerifySnapshot(&buf, GobCodec, 100); n != 2 || err != ErrUnsorted {
+		t.Errorf("VerifySnapshot of unsorted entries = %d, %v", n, err)
+	}
+}
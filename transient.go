@@ -0,0 +1,196 @@
+package safe_treap
+
+// owner identifies the nodes a Transient may modify in place.  It is not empty
+// so that every owner has a distinct address.
+type owner struct{ _ byte }
+
+// Transient is a mutable view of a treap for bursts of updates that do not
+// need every intermediate version.
+//
+// Nodes created by a Transient are modified in place by its later operations,
+// so a run of updates only copies each shared node once instead of copying a
+// whole path per update.  Nodes it did not create, which may be shared with
+// other roots, are path copied as usual.  Root publishes the current version:
+// from then on every node reachable from it is treated as shared again, so
+// persistence is paid for only when a version is actually kept.
+//
+// A Transient must not be used by several goroutines at once; the roots it
+// returns are ordinary immutable roots.
+type Transient struct {
+	t     *Treap
+	root  *Node
+	owner *owner
+}
+
+// Transient starts a sequence of in-place updates on n, which is not modified.
+func (t *Treap) Transient(n *Node) *Transient {
+	return &Transient{t: t, root: n, owner: new(owner)}
+}
+
+// Root returns the current version of the treap.  The returned root is never
+// modified by later operations of the Transient.
+func (tx *Transient) Root() *Node {
+	tx.owner = new(owner)
+	return tx.root
+}
+
+// Get an element by key.
+func (tx *Transient) Get(key interface{}) (interface{}, bool) {
+	return tx.t.Get(tx.root, key)
+}
+
+// Insert an element, returning false if the key is already present.  Like
+// InsertChecked, it fails without changing the treap if key or val exceed the
+// size limits or the insertion the depth limit of the treap.
+func (tx *Transient) Insert(key, val interface{}, weight int) (bool, error) {
+	if err := tx.t.checkWrite(tx.root, key, val); err != nil {
+		return false, err
+	}
+	res, ok := tx.insert(tx.root, key, val, weight)
+	tx.root = res
+	return ok, nil
+}
+
+// Upsert inserts key or replaces its item and weight, returning true if the key
+// was created.  It fails like Insert.
+func (tx *Transient) Upsert(key, val interface{}, weight int) (bool, error) {
+	if err := tx.t.checkWrite(tx.root, key, val); err != nil {
+		return false, err
+	}
+	old, ok := tx.t.GetNode(tx.root, key)
+	switch {
+	case !ok:
+		tx.root, _ = tx.insert(tx.root, key, val, weight)
+		return true, nil
+	case tx.t.h().CompareWeights(old.Weight, weight) == 0:
+		tx.root = tx.modify(tx.root, key, func(n *Node) { n.Item = val })
+	default:
+		tx.root, _ = tx.delete(tx.root, key)
+		tx.root, _ = tx.insert(tx.root, key, val, weight)
+	}
+	return false, nil
+}
+
+// Delete removes key, returning false if it was not present.
+func (tx *Transient) Delete(key interface{}) bool {
+	res, ok := tx.delete(tx.root, key)
+	tx.root = res
+	return ok
+}
+
+// edit returns n if the Transient owns it, or an owned copy of n.
+func (tx *Transient) edit(n *Node) *Node {
+	if n.owner == tx.owner {
+		return n
+	}
+	res := tx.t.clone(n, n.Left, n.Right)
+	res.owner = tx.owner
+	return res
+}
+
+func (tx *Transient) insert(n *Node, k, v interface{}, w int) (*Node, bool) {
+	if n == nil {
+		res := tx.t.newNode()
+		res.Weight, res.Key, res.Item, res.owner, res.size = w, k, v, tx.owner, 1
+		return res, true
+	}
+
+	switch comp := tx.t.h().CompareKeys(k, n.Key); {
+	case comp < 0:
+		// a created subtree root is always owned, so it can be rotated
+		// in place.
+		l, ok := tx.insert(n.Left, k, v, w)
+		if !ok {
+			return n, false
+		}
+		res := tx.edit(n)
+		if tx.t.h().CompareWeights(l.Weight, res.Weight) < 0 {
+			res.Left, l.Right = l.Right, res
+			resize(res)
+			resize(l)
+			return l, true
+		}
+		res.Left = l
+		resize(res)
+		return res, true
+	case comp > 0:
+		r, ok := tx.insert(n.Right, k, v, w)
+		if !ok {
+			return n, false
+		}
+		res := tx.edit(n)
+		if tx.t.h().CompareWeights(r.Weight, res.Weight) < 0 {
+			res.Right, r.Left = r.Left, res
+			resize(res)
+			resize(r)
+			return r, true
+		}
+		res.Right = r
+		resize(res)
+		return res, true
+	default:
+		return n, false
+	}
+}
+
+// modify applies fn to the node holding k, which must be present.
+func (tx *Transient) modify(n *Node, k interface{}, fn func(*Node)) *Node {
+	res := tx.edit(n)
+	switch comp := tx.t.h().CompareKeys(k, n.Key); {
+	case comp < 0:
+		res.Left = tx.modify(n.Left, k, fn)
+	case comp > 0:
+		res.Right = tx.modify(n.Right, k, fn)
+	default:
+		fn(res)
+	}
+	return res
+}
+
+func (tx *Transient) delete(n *Node, k interface{}) (*Node, bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	switch comp := tx.t.h().CompareKeys(k, n.Key); {
+	case comp < 0:
+		l, ok := tx.delete(n.Left, k)
+		if !ok {
+			return n, false
+		}
+		res := tx.edit(n)
+		res.Left = l
+		resize(res)
+		return res, true
+	case comp > 0:
+		r, ok := tx.delete(n.Right, k)
+		if !ok {
+			return n, false
+		}
+		res := tx.edit(n)
+		res.Right = r
+		resize(res)
+		return res, true
+	default:
+		return tx.join(n.Left, n.Right), true
+	}
+}
+
+func (tx *Transient) join(l, r *Node) *Node {
+	switch {
+	case l == nil:
+		return r
+	case r == nil:
+		return l
+	case tx.t.h().CompareWeights(l.Weight, r.Weight) <= 0:
+		res := tx.edit(l)
+		res.Right = tx.join(l.Right, r)
+		resize(res)
+		return res
+	default:
+		res := tx.edit(r)
+		res.Left = tx.join(l, r.Left)
+		resize(res)
+		return res
+	}
+}
@@ -0,0 +1,93 @@
+package safe_treap
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestTransient(t *testing.T) {
+	tr := NewIntTreap()
+	base := fill(t, tr, 500)
+	tx := tr.Transient(base)
+
+	for k := 500; k < 1000; k++ {
+		if ok, err := tx.Insert(k, k*10, rand.Int()); !ok || err != nil {
+			t.Fatalf("Insert(%d) = %v, %v", k, ok, err)
+		}
+	}
+	if ok, _ := tx.Insert(10, 0, 0); ok {
+		t.Error("Insert of an existing key succeeded")
+	}
+	for k := 0; k < 1000; k += 2 {
+		if !tx.Delete(k) {
+			t.Fatalf("Delete(%d) found nothing", k)
+		}
+	}
+	if created, _ := tx.Upsert(1, "one", 1); created {
+		t.Error("Upsert of an existing key reported it created")
+	}
+	if v, ok := tx.Get(1); !ok || v != "one" {
+		t.Errorf("Get(1) = %v, %v", v, ok)
+	}
+
+	root := tx.Root()
+	if err := tr.Validate(root); err != nil {
+		t.Fatal(err)
+	}
+	if tr.Len(root) != 500 {
+		t.Errorf("Len = %d, want 500", tr.Len(root))
+	}
+	if err := tr.Validate(base); err != nil || tr.Len(base) != 500 {
+		t.Fatalf("the base version was modified: %v", err)
+	}
+	if v, _ := tr.Get(base, 1); v != 10 {
+		t.Errorf("base Get(1) = %v, want 10", v)
+	}
+}
+
+func TestTransientPublishedRootsAreImmutable(t *testing.T) {
+	tr := NewIntTreap()
+	tx := tr.Transient(nil)
+	for k := 0; k < 100; k++ {
+		tx.Insert(k, k, rand.Int())
+	}
+	first := tx.Root()
+
+	for k := 0; k < 100; k++ {
+		tx.Upsert(k, -k, rand.Int())
+	}
+	for k := 0; k < 100; k += 3 {
+		tx.Delete(k)
+	}
+
+	if err := tr.Validate(first); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range tr.All(first) {
+		if k != v {
+			t.Fatalf("published root changed: %v = %v", k, v)
+		}
+	}
+	if err := tr.Validate(tx.Root()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTransientLimits(t *testing.T) {
+	tr, err := NewTreap(&Handle{CompareKeys: IntComparator, CompareWeights: IntComparator}, WithMaxValueSize(4, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := tr.Transient(nil)
+	if _, err := tx.Insert(1, "small", 0); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Insert of an oversized value: %v", err)
+	}
+	tx.Insert(1, "one", 0)
+	if _, err := tx.Upsert(1, "large", 0); !errors.Is(err, ErrValueTooLarge) {
+		t.Errorf("Upsert of an oversized value: %v", err)
+	}
+	if v, _ := tx.Get(1); v != "one" {
+		t.Errorf("Get(1) = %v after a rejected Upsert", v)
+	}
+}
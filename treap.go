@@ -1,85 +1,287 @@
 package safe_treap
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrNilHandle is the misuse of creating a treap without a Handle.
+var ErrNilHandle = errors.New("comparator is nil")
+
+// ErrKeyExists is matched by the errors returned when inserting a key that is
+// already in the treap.
+var ErrKeyExists = errors.New("key already exists")
+
+// KeyExistsError reports the key that prevented an insertion.
+type KeyExistsError struct {
+	Key interface{}
+}
+
+func (e *KeyExistsError) Error() string {
+	return fmt.Sprintf("key %v already exists", e.Key)
+}
+
+// Is makes errors.Is(err, ErrKeyExists) hold.
+func (e *KeyExistsError) Is(target error) bool {
+	return target == ErrKeyExists
+}
 
 // treap structure to define the root node
 //
-// The root is set by user
+// The root is set by user (see CompareAndSwapRoot)
+//
+// The zero value is ready to use: it orders keys of the builtin types with
+// OrderedComparator and weights with IntComparator (lowest weight at the root).
+// Use NewTreap to order any other key type.
+//
+// Nodes are never modified once they are reachable from a root, and the
+// Handle is copied when the treap is created, so the read path (Get, GetNode,
+// Min, Max and the iterators) only ever loads immutable state.  Any number of
+// goroutines may therefore read a published root concurrently without locking.
 type Treap struct {
-	handle  *Handle
-	root    *Node
+	config
+	root atomic.Pointer[Node]
+
+	snapshotMu sync.Mutex
+	snapshots  map[string]snapshot
+}
+
+// config is the part of a Treap set when it is created, which can be copied
+// to derive a treap working with the same settings.
+type config struct {
+	handle *Handle
+
+	allocator    Allocator
+	maxDepth     int
+	maxKeySize   sizeLimit
+	maxValueSize sizeLimit
+	policy       MisusePolicy
+	copyOnRead   func(interface{}) interface{}
+	equal        func(a, b interface{}) bool
+	counters     *opCounters
+	jsonDecode   func(data []byte, key bool) (interface{}, error)
+	marshal      func(v interface{}) []byte
+	retention    *RetentionPolicy
 }
 
 // node is the recursive data structure that defines a persistent treap
 //
-// The zero value is ready to use
+// Nodes are created by the treap, which maintains their unexported fields: a
+// Node built by hand has a subtree size of 0 and fails Validate.
+//
+// Meta is a slot for the user's own bookkeeping (dirty flags, cache hints...).
+// The treap never interprets it; it is carried along whenever the node is
+// copied and is visible to every visitor, but it is not serialized.
+//
+// Besides Meta, every node pays three words whether or not the features
+// using them are: the subtree size behind Len, Rank and Select in O(log n),
+// the owner that lets a Transient edit its own nodes in place, and the cached
+// Merkle hash.  The hash is cached in the node rather than in a table keyed
+// by node because such a table would keep every hashed version alive.
 type Node struct {
 	Weight int
 	Key, Item  interface{}
+	Meta       interface{}
 	Left, Right *Node
+
+	size  int                    // number of nodes in the subtree, maintained by the treap
+	owner *owner                 // the Transient allowed to modify the node in place, if any
+	hash  atomic.Pointer[digest] // cached by RootHash
 }
 
 
+// KV is a key and its value, as exported from or bulk loaded into a treap.
+type KV struct {
+	Key, Value interface{}
+}
+
 // Handle performs purely functional transformations on a treap.
 type Handle struct {
-	CompareWeights, CompareKeys Comparator
+	// CompareWeights orders the weights: the node whose weight compares
+	// lowest is the root, i.e. the treap is a min-heap on weights under
+	// CompareWeights.  Use MinHeap or MaxHeap rather than a comparator of
+	// your own to pick the direction explicitly.
+	CompareWeights Comparator
+	// CompareKeys orders the keys.
+	CompareKeys Comparator
+
+	// WeightSource draws the weights of the keys inserted without an
+	// explicit weight, e.g. by Put.  It must be safe for concurrent use if
+	// the treap is written concurrently.  If nil, weights are drawn from
+	// math/rand.  Inject a seeded source to make the shape of the treap
+	// reproducible in tests.
+	WeightSource func() int
+}
+
+// NewIntTreap creates a treap of int keys, to be filled with Put.
+func NewIntTreap() *Treap {
+	t, _ := NewTreap(&Handle{CompareKeys: IntComparator, CompareWeights: IntComparator})
+	return t
+}
+
+// NewStringTreap creates a treap of string keys, to be filled with Put.
+func NewStringTreap() *Treap {
+	t, _ := NewTreap(&Handle{CompareKeys: StringComparator, CompareWeights: IntComparator})
+	return t
 }
 
-func NewTreap(h *Handle) (*Treap, error) {
+// NewTreap creates a treap ordered by the comparators of h.  A nil comparator
+// in h falls back to the one used by the zero value of Treap.
+//
+// A nil h is misuse: depending on the misuse policy (see WithMisusePolicy)
+// NewTreap returns ErrNilHandle or panics with it.
+func NewTreap(h *Handle, opts ...Option) (*Treap, error) {
+	treap :=  &Treap{}
+	for _, opt := range opts {
+		opt(treap)
+	}
+
 	if h == nil {
-		return nil, errors.New("comparator is nil")
+		return nil, treap.misuse(ErrNilHandle)
 	}
-	treap :=  &Treap{handle: h, root: nil}
+	// copy the handle so that later changes made by the caller to h can not
+	// race with readers of this treap.
+	hc := *h
+	if hc.CompareKeys == nil {
+		hc.CompareKeys = defaultHandle.CompareKeys
+	}
+	if hc.CompareWeights == nil {
+		hc.CompareWeights = defaultHandle.CompareWeights
+	}
+	treap.handle = &hc
 
 	return treap, nil
 }
 
+// defaultHandle is used by the zero value of Treap.
+var defaultHandle = &Handle{
+	CompareKeys:    OrderedComparator,
+	CompareWeights: IntComparator,
+}
+
+// h returns the handle of t, falling back to defaultHandle for the zero value.
+func (t *Treap) h() *Handle {
+	if t.handle == nil {
+		return defaultHandle
+	}
+	return t.handle
+}
+
 // Get an element by key.  Returns nil if the key is not in the treap.
 // O(log n) if the treap is balanced (i.e. has uniformly distributed weights).
 func (t *Treap) Get(n *Node, key interface{}) (v interface{}, found bool) {
 	if n, found = t.GetNode(n, key); found {
-		v = n.Item
+		v = t.value(n.Item)
 	}
 	return
 }
 
 // GetNode returns the subtree whose root has the specified key.  This is equivalent to
 // Get, but returns a full node.
+//
+// The descent is iterative and only reads nodes, so it is safe to call from
+// any number of goroutines at once.
 func (t *Treap) GetNode(n *Node, key interface{}) (*Node, bool) {
-	if n == nil {
-		return nil, false
+	compare := t.h().CompareKeys
+	for n != nil {
+		switch comp := compare(key, n.Key); {
+		case comp < 0:
+			n = n.Left
+		case comp > 0:
+			n = n.Right
+		default:
+			return n, true
+		}
 	}
+	return nil, false
+}
 
-	switch comp := t.handle.CompareKeys(key, n.Key); {
-	case comp < 0:
-		return t.GetNode(n.Left, key)
-	case comp > 0:
-		return t.GetNode(n.Right, key)
-	default:
-		return n, true
-	}
+// LoadRoot returns the root of the treap, as last set by CompareAndSwapRoot.
+func (t *Treap) LoadRoot() *Node {
+	return t.root.Load()
+}
+
+// CompareAndSwapRoot sets the root of the treap to new if it is still old,
+// and reports whether it did.  Since roots are immutable, a read-copy-update
+// transaction is a loop that loads the root, derives a new root from it and
+// retries if the swap fails.
+func (t *Treap) CompareAndSwapRoot(old, new *Node) bool {
+	return t.root.CompareAndSwap(old, new)
 }
 
 func (t *Treap) Min() interface{} {
-	n := t.root
+	n := t.LoadRoot()
 	if n == nil {
 		return nil
 	}
 	for n.Left != nil {
 		n = n.Left
 	}
-	return n.Item
+	return t.value(n.Item)
 }
 
 func (t *Treap) Max() interface{} {
-	n := t.root
+	n := t.LoadRoot()
 	if n == nil {
 		return nil
 	}
 	for n.Right != nil {
 		n = n.Right
 	}
-	return n.Item
+	return t.value(n.Item)
+}
+
+// Len returns the number of keys of n.  It is recorded in every node, so Len
+// is O(1).
+func (t *Treap) Len(n *Node) int {
+	return nodeSize(n)
+}
+
+// Floor returns the greatest key of n that is <= key, along with its item,
+// and false if there is none.
+//
+// O(log n) if the treap is balanced (see Get).
+func (t *Treap) Floor(n *Node, key interface{}) (interface{}, interface{}, bool) {
+	if n = t.floor(n, key); n == nil {
+		return nil, nil, false
+	}
+	return n.Key, t.value(n.Item), true
+}
+
+// Ceiling returns the smallest key of n that is >= key, along with its item,
+// and false if there is none.
+//
+// O(log n) if the treap is balanced (see Get).
+func (t *Treap) Ceiling(n *Node, key interface{}) (interface{}, interface{}, bool) {
+	if n = t.ceil(n, key); n == nil {
+		return nil, nil, false
+	}
+	return n.Key, t.value(n.Item), true
+}
+
+// FindFirst returns the first entry of n in key order that satisfies pred,
+// and false if none does.  pred must be monotone over the key order: false
+// for a prefix of the entries and true for all the others.  Its result tells
+// the search which way to go, as in sort.Search, so only one path is walked
+// instead of every entry.
+//
+// O(log n) if the treap is balanced (see Get).
+func (t *Treap) FindFirst(n *Node, pred func(key, val interface{}) bool) (interface{}, interface{}, bool) {
+	var res *Node
+	for n != nil {
+		if pred(n.Key, t.value(n.Item)) {
+			res, n = n, n.Left
+		} else {
+			n = n.Right
+		}
+	}
+	if res == nil {
+		return nil, nil, false
+	}
+	return res.Key, t.value(res.Item), true
 }
 
 // Insert an element into the treap, returning false if the element is already present.
@@ -89,43 +291,114 @@ func (t *Treap) Insert(n *Node, key, val interface{}, weight int) (new *Node, ok
 	return t.upsert(n, key, val, weight, true, false, nil)
 }
 
+// Upsert inserts key or replaces its item and weight, returning true if key
+// was created.
+//
+// O(log n) if the treap is balanced (see Get).
+func (t *Treap) Upsert(n *Node, key, val interface{}, weight int) (*Node, bool) {
+	if t.equal != nil {
+		if old, ok := t.GetNode(n, key); ok && t.unchanged(old, val, weight) {
+			return n, false
+		}
+	}
+	return t.upsert(n, key, val, weight, true, true, nil)
+}
+
+// InsertMax inserts key, which must be greater than every key of n, returning
+// false (and n) otherwise.  This is the fast path for append-mostly
+// workloads such as time-ordered ingestion.
+//
+// The new node always ends up on the right spine, so instead of a full
+// descent InsertMax walks that spine, comparing weights to find where the
+// node belongs, and compares a single key to check the order.
+func (t *Treap) InsertMax(n *Node, key, val interface{}, weight int) (*Node, bool) {
+	var spine []*Node
+	for c := n; c != nil; c = c.Right {
+		spine = append(spine, c)
+	}
+	if len(spine) > 0 && t.h().CompareKeys(key, spine[len(spine)-1].Key) <= 0 {
+		return n, false
+	}
+
+	// the new node goes below the spine nodes that rank before it and takes
+	// the rest of the spine as its left subtree.
+	at := 0
+	for at < len(spine) && t.h().CompareWeights(spine[at].Weight, weight) <= 0 {
+		at++
+	}
+
+	res := t.newNode()
+	res.Weight, res.Key, res.Item = weight, key, val
+	if at < len(spine) {
+		res.Left = spine[at]
+	}
+	resize(res)
+	for i := at - 1; i >= 0; i-- {
+		res = t.clone(spine[i], spine[i].Left, res)
+	}
+	return res, true
+}
+
+// Put inserts key or replaces its item, returning true if key was created.
+//
+// New keys get a random weight, which keeps the treap balanced in expectation
+// whatever the order of insertion; existing keys keep their weight.
+func (t *Treap) Put(n *Node, key, val interface{}) (*Node, bool) {
+	if old, ok := t.GetNode(n, key); ok {
+		if !t.unchanged(old, val, old.Weight) {
+			n, _ = t.upsert(n, key, val, old.Weight, true, true, nil)
+		}
+		return n, false
+	}
+	return t.upsert(n, key, val, t.randomWeight(), true, false, nil)
+}
+
+// randomWeight draws the weight of nodes created without an explicit one.
+func (t *Treap) randomWeight() int {
+	if src := t.h().WeightSource; src != nil {
+		return src()
+	}
+	return rand.Int()
+}
+
+// InsertStrict is like Insert, but reports a key that is already present as a
+// *KeyExistsError (matching ErrKeyExists with errors.Is) instead of a boolean
+// that is easy to ignore.  Like InsertChecked it honors the depth and size
+// limits of the treap.
+func (t *Treap) InsertStrict(n *Node, key, val interface{}, weight int) (*Node, error) {
+	res, ok, err := t.InsertChecked(n, key, val, weight)
+	if err == nil && !ok {
+		err = &KeyExistsError{Key: key}
+	}
+	return res, err
+}
+
 func (t *Treap) upsert(n *Node, k, v interface{}, w int, create, update bool, fn func(*Node) bool) (res *Node, created bool) {
 	if n == nil {
 		if create {
 			created = true
-			res = &Node{Weight: w, Key: k, Item: v}
+			res = t.newNode()
+			res.Weight, res.Key, res.Item, res.size = w, k, v, 1
 		}
 
 		return
 	}
 
-	switch t.handle.CompareKeys(k, n.Key) {
-	case -1:
+	switch comp := t.h().CompareKeys(k, n.Key); {
+	case comp < 0:
 		// use res as temp variable to avoid extra allocation
 		if res, created = t.upsert(n.Left, k, v, w, create, update, fn); res == nil {
 			return
 		}
 
-		res = &Node{
-			Weight: n.Weight,
-			Key:    n.Key,
-			Item:   n.Item,
-			Left:   res,
-			Right:  n.Right,
-		}
-	case 1:
+		res = t.clone(n, res, n.Right)
+	case comp > 0:
 		// use res as temp variable to avoid extra allocation
 		if res, created = t.upsert(n.Right, k, v, w, create, update, fn); res == nil {
 			return
 		}
 
-		res = &Node{
-			Weight: n.Weight,
-			Key:    n.Key,
-			Item:   n.Item,
-			Left:   n.Left,
-			Right:  res,
-		}
+		res = t.clone(n, n.Left, res)
 	default:
 		if !update { // insert only (no upsert)
 			return
@@ -136,56 +409,263 @@ func (t *Treap) upsert(n *Node, k, v interface{}, w int, create, update bool, fn
 			return
 		}
 
-		res = &Node{
-			Weight: w,
-			Key:    n.Key,
-			Item:   n.Item,
-			Left:   n.Left,
-			Right:  n.Right,
-		}
+		res = t.clone(n, n.Left, n.Right)
+		res.Weight = w
 
 		if create { // not SetWeight
 			res.Item = v // upsert; set new value.
 		}
+
+		if t.h().CompareWeights(w, n.Weight) > 0 {
+			// the node may now rank after its children; a single rotation
+			// is not enough to restore the heap order below it.
+			res = t.sink(res)
+		}
 	}
 
-	if res.Left != nil && t.handle.CompareWeights(res.Left.Weight, res.Weight) < 0 {
+	if res.Left != nil && t.h().CompareWeights(res.Left.Weight, res.Weight) < 0 {
+		old := res
 		res = t.leftRotation(res)
-	} else if res.Right != nil && t.handle.CompareWeights(res.Right.Weight, res.Weight) < 0 {
+		t.free(old)
+	} else if res.Right != nil && t.h().CompareWeights(res.Right.Weight, res.Weight) < 0 {
+		old := res
 		res = t.rightRotation(res)
+		t.free(old)
 	}
 
 	return
 }
 
 func (t *Treap) leftRotation(n *Node) *Node {
-	return &Node{
-		Weight: n.Left.Weight,
-		Key:    n.Left.Key,
-		Item:   n.Left.Item,
-		Left:   n.Left.Left,
-		Right: &Node{
-			Weight: n.Weight,
-			Key:    n.Key,
-			Item:  n.Item,
-			Left:   n.Left.Right,
-			Right:  n.Right,
-		},
-	}
+	t.countRotation()
+	return t.clone(n.Left, n.Left.Left, t.clone(n, n.Left.Right, n.Right))
 }
 
 func (t *Treap) rightRotation(n *Node) *Node {
-	return &Node{
-		Weight: n.Right.Weight,
-		Key:    n.Right.Key,
-		Item:   n.Right.Item,
-		Left: &Node{
-			Weight: n.Weight,
-			Key:    n.Key,
-			Item:   n.Item,
-			Left:   n.Left,
-			Right:  n.Right.Left,
-		},
-		Right: n.Right.Right,
-	}
-}
\ No newline at end of file
+	t.countRotation()
+	return t.clone(n.Right, t.clone(n, n.Left, n.Right.Left), n.Right.Right)
+}
+
+// sink rotates n down until its weight ranks before those of its children.
+// n must be a fresh copy; the nodes it is rotated past are copied.
+func (t *Treap) sink(n *Node) *Node {
+	l, r := n.Left, n.Right
+	switch {
+	case l != nil && t.h().CompareWeights(l.Weight, n.Weight) < 0 &&
+		(r == nil || t.h().CompareWeights(l.Weight, r.Weight) <= 0):
+		n.Left = l.Right
+		t.countRotation()
+		return t.clone(l, l.Left, t.sink(n))
+	case r != nil && t.h().CompareWeights(r.Weight, n.Weight) < 0:
+		n.Right = r.Left
+		t.countRotation()
+		return t.clone(r, t.sink(n), r.Right)
+	default:
+		resize(n)
+		return n
+	}
+}
+
+// Split partitions n into the keys less than key and the keys greater than or
+// equal to key, copying only the search path.  n itself is left unchanged.
+//
+// O(log n) if the treap is balanced (see Get).
+func (t *Treap) Split(n *Node, key interface{}) (left, right *Node) {
+	left, found, right := t.split(n, key)
+	if found != nil {
+		right = t.join(t.clone(found, nil, nil), right)
+	}
+	return left, right
+}
+
+// Join concatenates two treaps of the same Treap.  Every key of left must be
+// less than every key of right; Join does not check it.
+//
+// O(log n) if the treaps are balanced (see Get).
+func (t *Treap) Join(left, right *Node) *Node {
+	return t.join(left, right)
+}
+
+// split partitions n into the keys less than key, the node holding key (or
+// nil) and the keys greater than key, copying only the search path.
+func (t *Treap) split(n *Node, key interface{}) (left, found, right *Node) {
+	if n == nil {
+		return nil, nil, nil
+	}
+
+	switch comp := t.h().CompareKeys(key, n.Key); {
+	case comp < 0:
+		left, found, right = t.split(n.Left, key)
+		return left, found, t.clone(n, right, n.Right)
+	case comp > 0:
+		left, found, right = t.split(n.Right, key)
+		return t.clone(n, n.Left, left), found, right
+	default:
+		return n.Left, n, n.Right
+	}
+}
+
+// Delete removes key from the treap, returning the new root and false if key
+// was not present (in which case n is returned).
+//
+// The node holding key is rotated down, always past the child that ranks
+// first, until it is a leaf and can be detached.  Only the search path and
+// the nodes the removed node is rotated past are copied.
+//
+// O(log n) if the treap is balanced (see Get).
+func (t *Treap) Delete(n *Node, key interface{}) (*Node, bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	switch comp := t.h().CompareKeys(key, n.Key); {
+	case comp < 0:
+		left, ok := t.Delete(n.Left, key)
+		if !ok {
+			return n, false
+		}
+		return t.clone(n, left, n.Right), true
+	case comp > 0:
+		right, ok := t.Delete(n.Right, key)
+		if !ok {
+			return n, false
+		}
+		return t.clone(n, n.Left, right), true
+	default:
+		return t.join(n.Left, n.Right), true
+	}
+}
+
+// PopMin removes the least key of n, returning the new root along with the
+// removed entry, and false if n is empty.  Together with PopMax it makes the
+// treap a persistent double-ended priority queue ordered by key.
+//
+// O(log n) if the treap is balanced (see Get).
+func (t *Treap) PopMin(n *Node) (*Node, KV, bool) {
+	if n == nil {
+		return nil, KV{}, false
+	}
+	if n.Left == nil {
+		return n.Right, KV{Key: n.Key, Value: t.value(n.Item)}, true
+	}
+	left, kv, _ := t.PopMin(n.Left)
+	return t.clone(n, left, n.Right), kv, true
+}
+
+// PopMax removes the greatest key of n, returning the new root along with the
+// removed entry, and false if n is empty.
+//
+// O(log n) if the treap is balanced (see Get).
+func (t *Treap) PopMax(n *Node) (*Node, KV, bool) {
+	if n == nil {
+		return nil, KV{}, false
+	}
+	if n.Right == nil {
+		return n.Left, KV{Key: n.Key, Value: t.value(n.Item)}, true
+	}
+	right, kv, _ := t.PopMax(n.Right)
+	return t.clone(n, n.Left, right), kv, true
+}
+
+// clone returns a copy of n with the given children.  Path copies go through
+// clone so that everything a node carries besides its children, including
+// Meta, survives the copy.
+func (t *Treap) clone(n, left, right *Node) *Node {
+	res := t.newNode()
+	*res = Node{
+		Weight: n.Weight,
+		Key:    n.Key,
+		Item:   n.Item,
+		Meta:   n.Meta,
+		Left:   left,
+		Right:  right,
+		size:   1 + nodeSize(left) + nodeSize(right),
+	}
+	return res
+}
+
+// nodeSize returns the number of nodes of n.
+func nodeSize(n *Node) int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+// resize recomputes the size of n after its children were changed in place.
+func resize(n *Node) {
+	n.size = 1 + nodeSize(n.Left) + nodeSize(n.Right)
+}
+
+// ascend visits the nodes of n in key order until fn returns false.  It
+// returns false if the walk was stopped early.
+func ascend(n *Node, fn func(*Node) bool) bool {
+	for n != nil {
+		if !ascend(n.Left, fn) || !fn(n) {
+			return false
+		}
+		n = n.Right
+	}
+	return true
+}
+
+// descend visits the nodes of n in reverse key order until fn returns false.
+// It returns false if the walk was stopped early.
+func descend(n *Node, fn func(*Node) bool) bool {
+	for n != nil {
+		if !descend(n.Right, fn) || !fn(n) {
+			return false
+		}
+		n = n.Left
+	}
+	return true
+}
+
+// join concatenates two treaps, all of whose keys in l are less than those in
+// r, copying only the nodes along the seam.  Joining the children of a node
+// is the same as rotating the node down to a leaf and detaching it.
+func (t *Treap) join(l, r *Node) *Node {
+	switch {
+	case l == nil:
+		return r
+	case r == nil:
+		return l
+	case t.h().CompareWeights(l.Weight, r.Weight) <= 0:
+		return t.clone(l, l.Left, t.join(l.Right, r))
+	default:
+		return t.clone(r, t.join(l, r.Left), r.Right)
+	}
+}
+
+// SetMeta returns a new root in which the node for key carries meta, or n and
+// false if key is not in the treap.  Only the path to the node is copied.
+func (t *Treap) SetMeta(n *Node, key, meta interface{}) (*Node, bool) {
+	return t.modify(n, key, func(n *Node) { n.Meta = meta })
+}
+
+// modify path-copies the search path for key and applies fn to the copy of
+// the node holding key.  fn must not change the key or the weight.
+func (t *Treap) modify(n *Node, key interface{}, fn func(*Node)) (*Node, bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	switch comp := t.h().CompareKeys(key, n.Key); {
+	case comp < 0:
+		left, ok := t.modify(n.Left, key, fn)
+		if !ok {
+			return n, false
+		}
+		return t.clone(n, left, n.Right), true
+	case comp > 0:
+		right, ok := t.modify(n.Right, key, fn)
+		if !ok {
+			return n, false
+		}
+		return t.clone(n, n.Left, right), true
+	default:
+		res := t.clone(n, n.Left, n.Right)
+		fn(res)
+		return res, true
+	}
+}
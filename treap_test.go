@@ -0,0 +1,204 @@
+package safe_treap
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// fill builds a treap of the keys 0..n-1 in random order, with k*10 as the
+// item of key k.
+func fill(t *testing.T, tr *Treap, n int) *Node {
+	t.Helper()
+	var root *Node
+	for _, k := range rand.Perm(n) {
+		root, _ = tr.Put(root, k, k*10)
+	}
+	if err := tr.Validate(root); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestInsertGetDelete(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 1000)
+	if got := tr.Len(root); got != 1000 {
+		t.Fatalf("Len = %d, want 1000", got)
+	}
+
+	if _, ok := tr.Insert(root, 10, 0, 0); ok {
+		t.Error("Insert of an existing key succeeded")
+	}
+	for _, k := range []int{0, 500, 999} {
+		if v, ok := tr.Get(root, k); !ok || v != k*10 {
+			t.Errorf("Get(%d) = %v, %v", k, v, ok)
+		}
+	}
+
+	smaller, ok := tr.Delete(root, 500)
+	if !ok {
+		t.Fatal("Delete(500) found nothing")
+	}
+	if err := tr.Validate(smaller); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tr.Get(smaller, 500); ok {
+		t.Error("deleted key still present")
+	}
+	if _, ok := tr.Get(root, 500); !ok {
+		t.Error("Delete modified the old version")
+	}
+}
+
+// TestConcurrentReaders reads published roots from many goroutines while a
+// writer keeps publishing new ones.  Run with -race.
+func TestConcurrentReaders(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		tr   *Treap
+	}{
+		{"zero", &Treap{}},
+		{"handle", NewIntTreap()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tr := tc.tr
+			tr.CompareAndSwapRoot(nil, fill(t, tr, 500))
+
+			const readers = 8
+			var wg sync.WaitGroup
+			stop := make(chan struct{})
+			for i := 0; i < readers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+
+						root := tr.LoadRoot()
+						if v, ok := tr.Get(root, 250); !ok || v != 2500 {
+							t.Errorf("Get(250) = %v, %v", v, ok)
+							return
+						}
+						count, prev := 0, -1
+						for k, v := range tr.All(root) {
+							if k.(int) <= prev || v != k.(int)*10 {
+								t.Errorf("All yielded %v: %v after %d", k, v, prev)
+								return
+							}
+							prev = k.(int)
+							count++
+						}
+						if count != tr.Len(root) {
+							t.Errorf("All yielded %d entries, Len is %d", count, tr.Len(root))
+							return
+						}
+					}
+				}()
+			}
+
+			for k := 500; k < 1500; k++ {
+				for {
+					old := tr.LoadRoot()
+					res, _ := tr.Put(old, k, k*10)
+					if tr.CompareAndSwapRoot(old, res) {
+						break
+					}
+				}
+			}
+			close(stop)
+			wg.Wait()
+
+			if err := tr.Validate(tr.LoadRoot()); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// TestMapModel runs random writes against a treap and a map side by side,
+// checking every version against the map, and the older versions against
+// the map they were taken from.
+func TestMapModel(t *testing.T) {
+	tr := NewIntTreap()
+	rnd := rand.New(rand.NewSource(1))
+	var root *Node
+	model := map[int]int{}
+
+	type version struct {
+		root  *Node
+		model map[int]int
+	}
+	var versions []version
+
+	for i := 0; i < 5000; i++ {
+		k, v := rnd.Intn(300), rnd.Int()
+		_, present := model[k]
+		switch rnd.Intn(5) {
+		case 0:
+			res, ok := tr.Insert(root, k, v, rnd.Int())
+			if ok == present {
+				t.Fatalf("Insert(%d) = %v with the key present: %v", k, ok, present)
+			}
+			if ok {
+				root, model[k] = res, v
+			}
+		case 1:
+			var created bool
+			if root, created = tr.Upsert(root, k, v, rnd.Int()); created == present {
+				t.Fatalf("Upsert(%d) created = %v", k, created)
+			}
+			model[k] = v
+		case 2:
+			var created bool
+			if root, created = tr.Put(root, k, v); created == present {
+				t.Fatalf("Put(%d) created = %v", k, created)
+			}
+			model[k] = v
+		case 3:
+			var ok bool
+			if root, ok = tr.Delete(root, k); ok != present {
+				t.Fatalf("Delete(%d) = %v", k, ok)
+			}
+			delete(model, k)
+		default:
+			left, right := tr.Split(root, k)
+			if _, ok := tr.Get(left, k); ok || tr.Len(left) != tr.Rank(root, k) {
+				t.Fatalf("Split(%d) left %d keys, Rank %d", k, tr.Len(left), tr.Rank(root, k))
+			}
+			root = tr.Join(left, right)
+		}
+
+		if i%100 == 0 {
+			if err := tr.Validate(root); err != nil {
+				t.Fatalf("after %d writes: %v", i, err)
+			}
+			snapshot := make(map[int]int, len(model))
+			for k, v := range model {
+				snapshot[k] = v
+			}
+			versions = append(versions, version{root, snapshot})
+		}
+	}
+
+	for i, ver := range versions {
+		if tr.Len(ver.root) != len(ver.model) {
+			t.Fatalf("version %d holds %d keys, want %d", i, tr.Len(ver.root), len(ver.model))
+		}
+		prev := -1
+		for k, v := range tr.All(ver.root) {
+			if want, ok := ver.model[k.(int)]; !ok || v != want || k.(int) <= prev {
+				t.Fatalf("version %d: key %v holds %v, want %v (present %v)", i, k, v, want, ok)
+			}
+			pos := tr.Rank(ver.root, k)
+			if sk, _, _ := tr.Select(ver.root, pos); sk != k {
+				t.Fatalf("version %d: Select(Rank(%v)) = %v", i, k, sk)
+			}
+			prev = k.(int)
+		}
+	}
+}
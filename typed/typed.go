@@ -0,0 +1,234 @@
+// Package typed is a generic counterpart of safe_treap: keys and items are
+// stored with their own types instead of being boxed in interface{} values,
+// so lookups need no type assertions.
+//
+// As in safe_treap, treaps are persistent: every method takes the root to
+// work on and returns a new root, leaving the nodes of the old one untouched.
+// Weights are ints, and lower weights sit closer to the root.
+package typed
+
+import (
+	"cmp"
+	"math/rand"
+)
+
+// Ordered is satisfied by the types whose values can be ordered with <.
+type Ordered = cmp.Ordered
+
+// Node is a node of a Treap.  Nodes are shared between versions and must not
+// be modified.
+type Node[K, V any] struct {
+	Weight      int
+	Key         K
+	Item        V
+	Left, Right *Node[K, V]
+}
+
+// Treap holds the ordering of the keys of a family of roots.
+type Treap[K, V any] struct {
+	compare func(a, b K) int
+}
+
+// New creates a treap ordered by compare, which returns a negative number if
+// a < b, zero if a == b and a positive number if a > b.
+func New[K, V any](compare func(a, b K) int) *Treap[K, V] {
+	return &Treap[K, V]{compare: compare}
+}
+
+// NewOrdered creates a treap whose keys are ordered by cmp.Compare, which
+// needs no comparator from the caller: an ordered map in one call.  NaNs are
+// less than every other float and equal to each other.
+func NewOrdered[K cmp.Ordered, V any]() *Treap[K, V] {
+	return New[K, V](cmp.Compare[K])
+}
+
+// Get returns the item of key.
+//
+// O(log n) if the treap is balanced.
+func (t *Treap[K, V]) Get(n *Node[K, V], key K) (V, bool) {
+	if n, ok := t.GetNode(n, key); ok {
+		return n.Item, true
+	}
+	var zero V
+	return zero, false
+}
+
+// GetNode returns the node holding key.
+func (t *Treap[K, V]) GetNode(n *Node[K, V], key K) (*Node[K, V], bool) {
+	for n != nil {
+		switch comp := t.compare(key, n.Key); {
+		case comp < 0:
+			n = n.Left
+		case comp > 0:
+			n = n.Right
+		default:
+			return n, true
+		}
+	}
+	return nil, false
+}
+
+// Min returns the node with the least key, or nil if n is empty.
+func (t *Treap[K, V]) Min(n *Node[K, V]) *Node[K, V] {
+	for n != nil && n.Left != nil {
+		n = n.Left
+	}
+	return n
+}
+
+// Max returns the node with the greatest key, or nil if n is empty.
+func (t *Treap[K, V]) Max(n *Node[K, V]) *Node[K, V] {
+	for n != nil && n.Right != nil {
+		n = n.Right
+	}
+	return n
+}
+
+// Insert adds key, returning n and false if key is already present.
+func (t *Treap[K, V]) Insert(n *Node[K, V], key K, val V, weight int) (*Node[K, V], bool) {
+	if _, ok := t.GetNode(n, key); ok {
+		return n, false
+	}
+	return t.insert(n, &Node[K, V]{Weight: weight, Key: key, Item: val}), true
+}
+
+// Upsert inserts key or replaces its item and weight, returning true if key
+// was created.
+func (t *Treap[K, V]) Upsert(n *Node[K, V], key K, val V, weight int) (*Node[K, V], bool) {
+	n, existed := t.Delete(n, key)
+	return t.insert(n, &Node[K, V]{Weight: weight, Key: key, Item: val}), !existed
+}
+
+// Put inserts key with a random weight or replaces its item, keeping its
+// weight, returning true if key was created.
+func (t *Treap[K, V]) Put(n *Node[K, V], key K, val V) (*Node[K, V], bool) {
+	if res, ok := t.replace(n, key, val); ok {
+		return res, false
+	}
+	return t.insert(n, &Node[K, V]{Weight: rand.Int(), Key: key, Item: val}), true
+}
+
+// Delete removes key, returning n and false if key was not present.
+func (t *Treap[K, V]) Delete(n *Node[K, V], key K) (*Node[K, V], bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	switch comp := t.compare(key, n.Key); {
+	case comp < 0:
+		left, ok := t.Delete(n.Left, key)
+		if !ok {
+			return n, false
+		}
+		return clone(n, left, n.Right), true
+	case comp > 0:
+		right, ok := t.Delete(n.Right, key)
+		if !ok {
+			return n, false
+		}
+		return clone(n, n.Left, right), true
+	default:
+		return t.Join(n.Left, n.Right), true
+	}
+}
+
+// Split partitions n into the keys less than key and the keys greater than or
+// equal to key.
+func (t *Treap[K, V]) Split(n *Node[K, V], key K) (left, right *Node[K, V]) {
+	left, found, right := t.split(n, key)
+	if found != nil {
+		right = t.insert(right, clone(found, nil, nil))
+	}
+	return left, right
+}
+
+// Join concatenates two treaps.  Every key of left must be less than every
+// key of right; Join does not check it.
+func (t *Treap[K, V]) Join(left, right *Node[K, V]) *Node[K, V] {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	case left.Weight <= right.Weight:
+		return clone(left, left.Left, t.Join(left.Right, right))
+	default:
+		return clone(right, t.Join(left, right.Left), right.Right)
+	}
+}
+
+// Ascend visits the nodes of n in key order until fn returns false.  It
+// returns false if the walk was stopped early.
+func (t *Treap[K, V]) Ascend(n *Node[K, V], fn func(*Node[K, V]) bool) bool {
+	for n != nil {
+		if !t.Ascend(n.Left, fn) || !fn(n) {
+			return false
+		}
+		n = n.Right
+	}
+	return true
+}
+
+// insert adds the fresh node x, whose key is not in n.
+func (t *Treap[K, V]) insert(n, x *Node[K, V]) *Node[K, V] {
+	if n == nil {
+		return x
+	}
+	if x.Weight < n.Weight {
+		x.Left, _, x.Right = t.split(n, x.Key)
+		return x
+	}
+	if t.compare(x.Key, n.Key) < 0 {
+		return clone(n, t.insert(n.Left, x), n.Right)
+	}
+	return clone(n, n.Left, t.insert(n.Right, x))
+}
+
+// replace path-copies n with val as the item of key.
+func (t *Treap[K, V]) replace(n *Node[K, V], key K, val V) (*Node[K, V], bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	switch comp := t.compare(key, n.Key); {
+	case comp < 0:
+		left, ok := t.replace(n.Left, key, val)
+		if !ok {
+			return n, false
+		}
+		return clone(n, left, n.Right), true
+	case comp > 0:
+		right, ok := t.replace(n.Right, key, val)
+		if !ok {
+			return n, false
+		}
+		return clone(n, n.Left, right), true
+	default:
+		res := clone(n, n.Left, n.Right)
+		res.Item = val
+		return res, true
+	}
+}
+
+// split partitions n into the keys less than key, the node holding key (or
+// nil) and the keys greater than key, copying only the search path.
+func (t *Treap[K, V]) split(n *Node[K, V], key K) (left, found, right *Node[K, V]) {
+	if n == nil {
+		return nil, nil, nil
+	}
+
+	switch comp := t.compare(key, n.Key); {
+	case comp < 0:
+		left, found, right = t.split(n.Left, key)
+		return left, found, clone(n, right, n.Right)
+	case comp > 0:
+		left, found, right = t.split(n.Right, key)
+		return clone(n, n.Left, left), found, right
+	default:
+		return n.Left, n, n.Right
+	}
+}
+
+func clone[K, V any](n, left, right *Node[K, V]) *Node[K, V] {
+	return &Node[K, V]{Weight: n.Weight, Key: n.Key, Item: n.Item, Left: left, Right: right}
+}
@@ -0,0 +1,144 @@
+package typed
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// check verifies the key order and heap order of n and returns its keys.
+func check[K, V any](t *testing.T, tr *Treap[K, V], n *Node[K, V]) []K {
+	t.Helper()
+	var keys []K
+	var walk func(n *Node[K, V])
+	walk = func(n *Node[K, V]) {
+		if n == nil {
+			return
+		}
+		for _, c := range []*Node[K, V]{n.Left, n.Right} {
+			if c != nil && c.Weight < n.Weight {
+				t.Fatalf("weight %d is above weight %d", n.Weight, c.Weight)
+			}
+		}
+		walk(n.Left)
+		if len(keys) > 0 && tr.compare(keys[len(keys)-1], n.Key) >= 0 {
+			t.Fatalf("key %v follows %v", n.Key, keys[len(keys)-1])
+		}
+		keys = append(keys, n.Key)
+		walk(n.Right)
+	}
+	walk(n)
+	return keys
+}
+
+func TestTreap(t *testing.T) {
+	tr := NewOrdered[int, string]()
+	var root *Node[int, string]
+	model := map[int]string{}
+	for i := 0; i < 2000; i++ {
+		k := rand.Intn(200)
+		switch rand.Intn(4) {
+		case 0:
+			var created bool
+			root, created = tr.Put(root, k, "put")
+			if _, ok := model[k]; ok == created {
+				t.Fatalf("Put(%d) created = %v", k, created)
+			}
+			model[k] = "put"
+		case 1:
+			var inserted bool
+			root, inserted = tr.Insert(root, k, "insert", rand.Int())
+			if _, ok := model[k]; ok == inserted {
+				t.Fatalf("Insert(%d) = %v", k, inserted)
+			}
+			if inserted {
+				model[k] = "insert"
+			}
+		case 2:
+			root, _ = tr.Upsert(root, k, "upsert", rand.Int())
+			model[k] = "upsert"
+		default:
+			var deleted bool
+			root, deleted = tr.Delete(root, k)
+			if _, ok := model[k]; ok != deleted {
+				t.Fatalf("Delete(%d) = %v", k, deleted)
+			}
+			delete(model, k)
+		}
+	}
+
+	if keys := check(t, tr, root); len(keys) != len(model) {
+		t.Fatalf("%d keys, want %d", len(keys), len(model))
+	}
+	for k, v := range model {
+		if got, ok := tr.Get(root, k); !ok || got != v {
+			t.Errorf("Get(%d) = %q, %v, want %q", k, got, ok, v)
+		}
+	}
+	if _, ok := tr.Get(root, 200); ok {
+		t.Error("Get of a missing key succeeded")
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	tr := NewOrdered[string, int]()
+	v1, _ := tr.Put(nil, "a", 1)
+	v2, _ := tr.Put(v1, "a", 2)
+	v3, _ := tr.Delete(v2, "a")
+	if got, _ := tr.Get(v1, "a"); got != 1 {
+		t.Errorf("the first version reads %d", got)
+	}
+	if got, _ := tr.Get(v2, "a"); got != 2 {
+		t.Errorf("the second version reads %d", got)
+	}
+	if v3 != nil {
+		t.Error("deleting the only key left a node")
+	}
+	if res, ok := tr.Delete(v1, "b"); ok || res != v1 {
+		t.Error("deleting a missing key copied the treap")
+	}
+
+	v4, _ := tr.Upsert(v1, "a", 3, -1)
+	if n, _ := tr.GetNode(v1, "a"); n.Weight == -1 {
+		t.Error("Upsert changed the node of an older version")
+	}
+	if n, _ := tr.GetNode(v4, "a"); n.Item != 3 || n.Weight != -1 {
+		t.Errorf("Upsert stored %d/%d", n.Item, n.Weight)
+	}
+}
+
+func TestSplitJoin(t *testing.T) {
+	tr := New[int, int](func(a, b int) int { return b - a }) // descending
+	var root *Node[int, int]
+	for k := 0; k < 100; k++ {
+		root, _ = tr.Put(root, k, k)
+	}
+	if tr.Min(root).Key != 99 || tr.Max(root).Key != 0 {
+		t.Errorf("Min = %d, Max = %d", tr.Min(root).Key, tr.Max(root).Key)
+	}
+
+	left, right := tr.Split(root, 40)
+	if keys := check(t, tr, left); len(keys) != 59 || keys[len(keys)-1] != 41 {
+		t.Errorf("left part ends at %v", keys[len(keys)-1])
+	}
+	if keys := check(t, tr, right); len(keys) != 41 || keys[0] != 40 {
+		t.Errorf("right part starts at %v", keys[0])
+	}
+	if keys := check(t, tr, tr.Join(left, right)); len(keys) != 100 {
+		t.Errorf("Join has %d keys", len(keys))
+	}
+	if len(check(t, tr, root)) != 100 {
+		t.Error("Split modified its input")
+	}
+
+	var visited []int
+	tr.Ascend(root, func(n *Node[int, int]) bool {
+		visited = append(visited, n.Key)
+		return len(visited) < 3
+	})
+	if len(visited) != 3 || visited[0] != 99 || visited[2] != 97 {
+		t.Errorf("Ascend visited %v", visited)
+	}
+	if tr.Min(nil) != nil || tr.Max(nil) != nil {
+		t.Error("Min or Max of an empty treap")
+	}
+}
@@ -0,0 +1,67 @@
+package safe_treap
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalid is matched by the errors of Validate.
+var ErrInvalid = errors.New("treap invariant violated")
+
+// InvariantError reports the node of key breaking an invariant of the treap.
+type InvariantError struct {
+	Key    interface{}
+	Reason string
+}
+
+func (e *InvariantError) Error() string {
+	return fmt.Sprintf("%v at key %v: %s", ErrInvalid, e.Key, e.Reason)
+}
+
+// Is makes errors.Is(err, ErrInvalid) hold.
+func (e *InvariantError) Is(target error) bool {
+	return target == ErrInvalid
+}
+
+// Validate checks every node of n with the comparators of the Handle: keys
+// must be strictly increasing in order, no child may have a weight ranking
+// before the weight of its parent, and subtree sizes must add up.  It
+// returns an *InvariantError naming the first offending key, e.g. to catch
+// inconsistent comparators or in fuzz tests.  O(n).
+func (t *Treap) Validate(n *Node) error {
+	_, err := t.validate(n, nil, nil)
+	return err
+}
+
+// validate checks n, whose keys must lie strictly between the keys of lo and
+// hi when not nil, and returns its size.
+func (t *Treap) validate(n, lo, hi *Node) (int, error) {
+	if n == nil {
+		return 0, nil
+	}
+
+	switch {
+	case lo != nil && t.h().CompareKeys(lo.Key, n.Key) >= 0:
+		return 0, &InvariantError{Key: n.Key, Reason: fmt.Sprintf("not after key %v", lo.Key)}
+	case hi != nil && t.h().CompareKeys(n.Key, hi.Key) >= 0:
+		return 0, &InvariantError{Key: n.Key, Reason: fmt.Sprintf("not before key %v", hi.Key)}
+	}
+	for _, c := range [...]*Node{n.Left, n.Right} {
+		if c != nil && t.h().CompareWeights(n.Weight, c.Weight) > 0 {
+			return 0, &InvariantError{Key: c.Key, Reason: fmt.Sprintf("weight %d ranks before weight %d of parent %v", c.Weight, n.Weight, n.Key)}
+		}
+	}
+
+	left, err := t.validate(n.Left, lo, n)
+	if err != nil {
+		return 0, err
+	}
+	right, err := t.validate(n.Right, n, hi)
+	if err != nil {
+		return 0, err
+	}
+	if size := 1 + left + right; n.size != size {
+		return 0, &InvariantError{Key: n.Key, Reason: fmt.Sprintf("size %d, subtree holds %d nodes", n.size, size)}
+	}
+	return n.size, nil
+}
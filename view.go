@@ -0,0 +1,188 @@
+package safe_treap
+
+// Unbounded may be passed as either bound of a range to leave that side of the
+// range open: as the lower bound it means "from the first key", as the upper
+// bound "to the last key".  Unlike a nil key, it never collides with a key
+// stored in the treap.
+var Unbounded interface{} = unbounded{}
+
+type unbounded struct{}
+
+// before reports whether key is below the lower bound lo.
+func (t *Treap) before(key, lo interface{}) bool {
+	return lo != Unbounded && t.h().CompareKeys(key, lo) < 0
+}
+
+// after reports whether key is above the upper bound hi.
+func (t *Treap) after(key, hi interface{}) bool {
+	return hi != Unbounded && t.h().CompareKeys(key, hi) > 0
+}
+
+// View is a read-only window on the keys in [lo, hi] of a treap.  It does not
+// copy anything: every query runs against the shared nodes and is clamped to
+// the range, so a View can be handed out to give scoped access to part of a
+// shared index.  Like the treap itself, a View is safe for concurrent use.
+type View struct {
+	t      *Treap
+	root   *Node
+	lo, hi interface{}
+}
+
+// View returns a view of the keys of n between lo and hi inclusive.  Either
+// bound may be Unbounded.
+func (t *Treap) View(n *Node, lo, hi interface{}) *View {
+	return &View{t: t, root: n, lo: lo, hi: hi}
+}
+
+// Contains reports whether key lies within the bounds of the view.
+func (v *View) Contains(key interface{}) bool {
+	return !v.t.before(key, v.lo) && !v.t.after(key, v.hi)
+}
+
+// Get an element by key.  Keys outside of the view are never found.
+func (v *View) Get(key interface{}) (interface{}, bool) {
+	if !v.Contains(key) {
+		return nil, false
+	}
+	return v.t.Get(v.root, key)
+}
+
+// Min returns the item with the smallest key in the view, or nil if the view
+// is empty.
+func (v *View) Min() interface{} {
+	if n := v.t.ceilBound(v.root, v.lo); n != nil && v.Contains(n.Key) {
+		return v.t.value(n.Item)
+	}
+	return nil
+}
+
+// Max returns the item with the greatest key in the view, or nil if the view
+// is empty.
+func (v *View) Max() interface{} {
+	if n := v.t.floorBound(v.root, v.hi); n != nil && v.Contains(n.Key) {
+		return v.t.value(n.Item)
+	}
+	return nil
+}
+
+// Ascend visits the nodes of the view in key order until fn returns false.
+// Subtrees outside of the view are not visited.
+func (v *View) Ascend(fn func(*Node) bool) {
+	v.t.ascendRange(v.root, v.lo, v.hi, func(n *Node) bool {
+		return fn(v.t.visible(n))
+	})
+}
+
+// View narrows the view to the keys in [lo, hi] that it already contains.
+func (v *View) View(lo, hi interface{}) *View {
+	if lo == Unbounded || v.t.before(lo, v.lo) {
+		lo = v.lo
+	}
+	if hi == Unbounded || v.t.after(hi, v.hi) {
+		hi = v.hi
+	}
+	return &View{t: v.t, root: v.root, lo: lo, hi: hi}
+}
+
+// AppendRange appends the entries of n with keys in [lo, hi] to dst in key
+// order and returns the extended slice.  Either bound may be Unbounded.  The
+// only allocations are those made by append, so none when dst has enough
+// spare capacity; reusing dst[:0] across requests keeps hot range endpoints
+// allocation free.
+func (t *Treap) AppendRange(dst []KV, n *Node, lo, hi interface{}) []KV {
+	t.ascendRange(n, lo, hi, func(n *Node) bool {
+		dst = append(dst, KV{Key: n.Key, Value: t.value(n.Item)})
+		return true
+	})
+	return dst
+}
+
+// ascendRange visits the nodes of n with keys in [lo, hi] in key order until
+// fn returns false, pruning the subtrees outside of the range.  It returns
+// false if the walk was stopped early.
+func (t *Treap) ascendRange(n *Node, lo, hi interface{}, fn func(*Node) bool) bool {
+	for n != nil {
+		switch {
+		case t.before(n.Key, lo):
+			n = n.Right
+		case t.after(n.Key, hi):
+			n = n.Left
+		default:
+			if !t.ascendRange(n.Left, lo, hi, fn) || !fn(n) {
+				return false
+			}
+			n = n.Right
+		}
+	}
+	return true
+}
+
+// descendRange is like ascendRange in reverse key order.
+func (t *Treap) descendRange(n *Node, lo, hi interface{}, fn func(*Node) bool) bool {
+	for n != nil {
+		switch {
+		case t.before(n.Key, lo):
+			n = n.Right
+		case t.after(n.Key, hi):
+			n = n.Left
+		default:
+			if !t.descendRange(n.Right, lo, hi, fn) || !fn(n) {
+				return false
+			}
+			n = n.Left
+		}
+	}
+	return true
+}
+
+// ceilBound is like ceil, returning the first node if lo is Unbounded.
+func (t *Treap) ceilBound(n *Node, lo interface{}) *Node {
+	if lo != Unbounded {
+		return t.ceil(n, lo)
+	}
+	for n != nil && n.Left != nil {
+		n = n.Left
+	}
+	return n
+}
+
+// floorBound is like floor, returning the last node if hi is Unbounded.
+func (t *Treap) floorBound(n *Node, hi interface{}) *Node {
+	if hi != Unbounded {
+		return t.floor(n, hi)
+	}
+	for n != nil && n.Right != nil {
+		n = n.Right
+	}
+	return n
+}
+
+// ceil returns the node with the smallest key >= key, or nil.
+func (t *Treap) ceil(n *Node, key interface{}) (res *Node) {
+	for n != nil {
+		switch comp := t.h().CompareKeys(key, n.Key); {
+		case comp < 0:
+			res, n = n, n.Left
+		case comp > 0:
+			n = n.Right
+		default:
+			return n
+		}
+	}
+	return
+}
+
+// floor returns the node with the greatest key <= key, or nil.
+func (t *Treap) floor(n *Node, key interface{}) (res *Node) {
+	for n != nil {
+		switch comp := t.h().CompareKeys(key, n.Key); {
+		case comp < 0:
+			n = n.Left
+		case comp > 0:
+			res, n = n, n.Right
+		default:
+			return n
+		}
+	}
+	return
+}
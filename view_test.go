@@ -0,0 +1,63 @@
+package safe_treap
+
+import "testing"
+
+func viewKeys(v *View) []int {
+	var keys []int
+	v.Ascend(func(n *Node) bool {
+		keys = append(keys, n.Key.(int))
+		return true
+	})
+	return keys
+}
+
+func TestView(t *testing.T) {
+	tr := NewIntTreap()
+	root := fill(t, tr, 100)
+	v := tr.View(root, 10, 19)
+
+	if keys := viewKeys(v); len(keys) != 10 || keys[0] != 10 || keys[9] != 19 {
+		t.Errorf("the view holds %v", keys)
+	}
+	if !v.Contains(15) || v.Contains(9) || v.Contains(20) {
+		t.Error("Contains ignores the bounds")
+	}
+	if _, ok := v.Get(50); ok {
+		t.Error("a key outside of the view was found")
+	}
+	if x, ok := v.Get(12); !ok || x != 120 {
+		t.Errorf("Get(12) = %v, %v", x, ok)
+	}
+	if v.Min() != 100 || v.Max() != 190 {
+		t.Errorf("Min = %v, Max = %v", v.Min(), v.Max())
+	}
+
+	narrow := v.View(15, Unbounded)
+	if keys := viewKeys(narrow); len(keys) != 5 || keys[0] != 15 {
+		t.Errorf("the narrowed view holds %v", keys)
+	}
+	if keys := viewKeys(v.View(0, 200)); len(keys) != 10 {
+		t.Errorf("View widened the view to %v", keys)
+	}
+
+	empty := tr.View(root, 200, Unbounded)
+	if empty.Min() != nil || empty.Max() != nil || len(viewKeys(empty)) != 0 {
+		t.Error("an empty view has items")
+	}
+	if keys := viewKeys(tr.View(root, Unbounded, Unbounded)); len(keys) != 100 {
+		t.Errorf("an unbounded view holds %d keys", len(keys))
+	}
+}
+
+func TestViewAscendStops(t *testing.T) {
+	tr := NewIntTreap()
+	v := tr.View(fill(t, tr, 100), 40, Unbounded)
+	var keys []interface{}
+	v.Ascend(func(n *Node) bool {
+		keys = append(keys, n.Key)
+		return len(keys) < 3
+	})
+	if len(keys) != 3 || keys[2] != 42 {
+		t.Errorf("Ascend visited %v", keys)
+	}
+}
@@ -0,0 +1,148 @@
+package safe_treap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"sync"
+)
+
+// ErrCorruptLog is returned when replaying a write-ahead log record that does
+// not match its checksum or kind.
+var ErrCorruptLog = errors.New("write-ahead log record is corrupt")
+
+// maxLogRecord bounds the size of a record, so that a damaged length cannot
+// make Replay allocate without limit.
+const maxLogRecord = 64 << 20
+
+// WAL appends the mutations of a treap to a log, so that the treap can be
+// rebuilt after a crash by replaying the log over the last snapshot instead of
+// serializing the whole tree on every change.
+//
+// Every record is framed on its own as
+//
+//	length (uvarint) | crc32 (4 bytes, big endian) | kind (1 byte) | entry
+//
+// where the entry is a Record encoded with the codec of the log, and is
+// written with a single call to Write.  Records are limited to 64 MiB.  A WAL
+// is safe for concurrent use.
+type WAL struct {
+	w     io.Writer
+	codec Codec
+
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// NewWAL creates a log appending to w, typically a file opened with
+// O_APPEND.  Durability is up to w: sync the file after Append to survive a
+// power loss, not just a crash of the process.
+func NewWAL(w io.Writer, codec Codec) *WAL {
+	return &WAL{w: w, codec: codec}
+}
+
+// Append logs op.  Log it before publishing the root that op produces, and
+// give the log the same weight the treap uses (e.g. for Put, the weight the
+// key ends up with) so that replay rebuilds the same treap.  A record over
+// the size limit fails with a *SizeError.
+func (l *WAL) Append(op Op) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf.Reset()
+	l.buf.WriteByte(byte(op.Kind))
+	rec := Record{Key: op.Key, Item: op.Value, Weight: op.Weight}
+	if err := l.codec.NewEncoder(&l.buf).Encode(&rec); err != nil {
+		return err
+	}
+
+	payload := l.buf.Bytes()
+	if len(payload) > maxLogRecord {
+		return &SizeError{Field: "log record", Size: len(payload), Max: maxLogRecord}
+	}
+	frame := binary.AppendUvarint(nil, uint64(len(payload)))
+	frame = binary.BigEndian.AppendUint32(frame, crc32.ChecksumIEEE(payload))
+	frame = append(frame, payload...)
+	_, err := l.w.Write(frame)
+	return err
+}
+
+// Insert logs an OpInsert.
+func (l *WAL) Insert(key, val interface{}, weight int) error {
+	return l.Append(Op{Kind: OpInsert, Key: key, Value: val, Weight: weight})
+}
+
+// Upsert logs an OpPut.
+func (l *WAL) Upsert(key, val interface{}, weight int) error {
+	return l.Append(Op{Kind: OpPut, Key: key, Value: val, Weight: weight})
+}
+
+// Delete logs an OpDelete.
+func (l *WAL) Delete(key interface{}) error {
+	return l.Append(Op{Kind: OpDelete, Key: key})
+}
+
+// Replay applies the operations logged in r to n, returning the new root and
+// the number of records applied.  An incomplete last record, as left by a
+// crash in the middle of an Append, is ignored; a damaged record anywhere
+// else yields ErrCorruptLog.  A record failing the limits of t (see Apply)
+// stops the replay with its error and is not counted.
+func (t *Treap) Replay(n *Node, r io.Reader, codec Codec) (*Node, int, error) {
+	br := bufio.NewReader(r)
+	applied := 0
+	for {
+		size, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			return n, applied, nil
+		} else if err != nil {
+			return n, applied, tornTail(err)
+		}
+
+		if size > maxLogRecord {
+			return n, applied, ErrCorruptLog
+		}
+
+		// the frame buffer grows with the bytes actually read, not with the
+		// length the log claims.
+		var buf bytes.Buffer
+		if _, err := io.CopyN(&buf, br, int64(4+size)); err != nil {
+			return n, applied, tornTail(err)
+		}
+		frame := buf.Bytes()
+		payload := frame[4:]
+		if size == 0 || crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(frame) {
+			if _, err := br.Peek(1); err == io.EOF {
+				return n, applied, nil // torn last record
+			}
+			return n, applied, ErrCorruptLog
+		}
+
+		op := Op{Kind: OpKind(payload[0])}
+		if op.Kind != OpPut && op.Kind != OpDelete && op.Kind != OpInsert {
+			return n, applied, ErrCorruptLog
+		}
+		var rec Record
+		if err := codec.NewDecoder(bytes.NewReader(payload[1:])).Decode(&rec); err != nil {
+			return n, applied, err
+		}
+		op.Key, op.Value, op.Weight = rec.Key, rec.Item, rec.Weight
+
+		res, err := t.Apply(n, []Op{op})
+		if err != nil {
+			return n, applied, err
+		}
+		n = res
+		applied++
+	}
+}
+
+// tornTail treats a log ending in the middle of a record as complete.
+func tornTail(err error) error {
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return nil
+	}
+	return err
+}
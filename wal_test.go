@@ -0,0 +1,97 @@
+package safe_treap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// logged writes a log of a few operations and returns it with the treap they
+// produce.
+func logged(t *testing.T, tr *Treap) ([]byte, *Node) {
+	t.Helper()
+	var buf bytes.Buffer
+	l := NewWAL(&buf, GobCodec)
+	var root *Node
+	for k := 0; k < 100; k++ {
+		if err := l.Insert(k, k*10, k); err != nil {
+			t.Fatal(err)
+		}
+		root, _ = tr.Insert(root, k, k*10, k)
+	}
+	for k := 0; k < 100; k += 3 {
+		if err := l.Delete(k); err != nil {
+			t.Fatal(err)
+		}
+		root, _ = tr.Delete(root, k)
+	}
+	if err := l.Upsert(1, "one", 1); err != nil {
+		t.Fatal(err)
+	}
+	root, _ = tr.Upsert(root, 1, "one", 1)
+	return buf.Bytes(), root
+}
+
+func TestReplay(t *testing.T) {
+	tr := NewIntTreap()
+	log, want := logged(t, tr)
+
+	got, applied, err := tr.Replay(nil, bytes.NewReader(log), GobCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != 100+34+1 {
+		t.Errorf("applied %d records", applied)
+	}
+	if added, removed, changed := tr.Diff(want, got); len(added)+len(removed)+len(changed) != 0 {
+		t.Errorf("replayed treap differs: +%v -%v ~%v", added, removed, changed)
+	}
+}
+
+func TestReplayTornTail(t *testing.T) {
+	tr := NewIntTreap()
+	log, _ := logged(t, tr)
+
+	_, all, err := tr.Replay(nil, bytes.NewReader(log), GobCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for cut := len(log) - 1; cut > len(log)-20; cut-- {
+		_, applied, err := tr.Replay(nil, bytes.NewReader(log[:cut]), GobCodec)
+		if err != nil || applied != all-1 {
+			t.Errorf("cut at %d: applied %d, %v", cut, applied, err)
+		}
+	}
+}
+
+func TestReplayCorrupt(t *testing.T) {
+	tr := NewIntTreap()
+	log, _ := logged(t, tr)
+	log[10] ^= 0xff
+
+	if _, _, err := tr.Replay(nil, bytes.NewReader(log), GobCodec); err != ErrCorruptLog {
+		t.Errorf("err = %v, want ErrCorruptLog", err)
+	}
+}
+
+func TestReplayHugeLength(t *testing.T) {
+	tr := NewIntTreap()
+	for _, size := range []uint64{maxLogRecord + 1, 1 << 62} {
+		log := append(binary.AppendUvarint(nil, size), 1, 2, 3, 4, 5)
+		if _, _, err := tr.Replay(nil, bytes.NewReader(log), GobCodec); err != ErrCorruptLog {
+			t.Errorf("length %d: err = %v, want ErrCorruptLog", size, err)
+		}
+	}
+}
+
+func TestAppendTooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	err := NewWAL(&buf, GobCodec).Upsert(1, make([]byte, maxLogRecord), 1)
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("err = %v, want ErrTooLarge", err)
+	}
+	if buf.Len() != 0 {
+		t.Error("oversized record was written")
+	}
+}
@@ -0,0 +1,40 @@
+package safe_treap
+
+// WalkDecision tells WalkCtl how to carry on after visiting a node.  SkipLeft
+// and SkipRight may be combined to skip both subtrees.
+type WalkDecision int
+
+// Continue visits both subtrees of the node.
+const Continue WalkDecision = 0
+
+const (
+	// SkipLeft does not visit the left subtree of the node.
+	SkipLeft WalkDecision = 1 << iota
+	// SkipRight does not visit the right subtree of the node.
+	SkipRight
+	// Stop ends the walk.
+	Stop
+)
+
+// WalkCtl visits n in pre-order, every node before its left and then its
+// right subtree, letting fn prune the walk at every node.  It returns false if
+// fn stopped the walk.
+//
+// The walk keeps no state besides the recursion, so fn may call any method of
+// the treap, including WalkCtl itself.
+func (t *Treap) WalkCtl(n *Node, fn func(*Node) WalkDecision) bool {
+	for n != nil {
+		d := fn(t.visible(n))
+		if d&Stop != 0 {
+			return false
+		}
+		if d&SkipLeft == 0 && !t.WalkCtl(n.Left, fn) {
+			return false
+		}
+		if d&SkipRight != 0 {
+			break
+		}
+		n = n.Right
+	}
+	return true
+}
@@ -0,0 +1,231 @@
+package safe_treap
+
+import (
+	"container/heap"
+	"math"
+)
+
+// AscendByWeight visits the nodes of n in weight order, starting with the
+// node the weight comparator ranks first (the root), until fn returns false.
+//
+// Because the treap is heap-ordered on weights, the next node in weight order
+// is always a child of a node already visited.  The walk therefore only keeps
+// a frontier of candidates in an auxiliary heap, which holds O(k) nodes after
+// k visits, and costs O(log k) per visited node.  Nodes with equal weights
+// are visited in no particular order.
+func (t *Treap) AscendByWeight(n *Node, fn func(*Node) bool) {
+	if n == nil {
+		return
+	}
+
+	f := &frontier{compare: t.h().CompareWeights, nodes: []*Node{n}}
+	for f.Len() > 0 {
+		n := heap.Pop(f).(*Node)
+		if !fn(t.visible(n)) {
+			return
+		}
+		if n.Left != nil {
+			heap.Push(f, n.Left)
+		}
+		if n.Right != nil {
+			heap.Push(f, n.Right)
+		}
+	}
+}
+
+// frontier is a heap of nodes ordered by weight (see container/heap)
+type frontier struct {
+	compare Comparator
+	nodes   []*Node
+}
+
+func (f *frontier) Len() int           { return len(f.nodes) }
+func (f *frontier) Less(i, j int) bool { return f.compare(f.nodes[i].Weight, f.nodes[j].Weight) < 0 }
+func (f *frontier) Swap(i, j int)      { f.nodes[i], f.nodes[j] = f.nodes[j], f.nodes[i] }
+func (f *frontier) Push(x interface{}) { f.nodes = append(f.nodes, x.(*Node)) }
+
+func (f *frontier) Pop() interface{} {
+	last := len(f.nodes) - 1
+	n := f.nodes[last]
+	f.nodes[last] = nil
+	f.nodes = f.nodes[:last]
+	return n
+}
+
+// SplitByWeight partitions n into the entries whose weight the weight
+// comparator orders before threshold (below) and the rest (above).
+//
+// Since the treap is heap-ordered on weights, the entries below the threshold
+// form the top of the tree: below is n with every subtree rooted at or above
+// the threshold cut off, and above is the concatenation of the cut subtrees.
+// Untouched subtrees are shared with n, so the cost is proportional to the
+// size of below rather than of the whole treap.
+func (t *Treap) SplitByWeight(n *Node, threshold int) (below, above *Node) {
+	if n == nil {
+		return nil, nil
+	}
+	if t.h().CompareWeights(n.Weight, threshold) >= 0 {
+		return nil, n
+	}
+
+	lb, la := t.SplitByWeight(n.Left, threshold)
+	rb, ra := t.SplitByWeight(n.Right, threshold)
+
+	below = n
+	if lb != n.Left || rb != n.Right {
+		below = t.clone(n, lb, rb)
+	}
+	return below, t.join(la, ra)
+}
+
+// BoostRange adds delta to the weight of every entry with a key in [lo, hi],
+// e.g. to escalate a whole group of jobs at once.  Either bound may be
+// Unbounded.  Whether a positive delta raises or lowers the priority of the
+// entries depends on the weight comparator; with IntComparator the smallest
+// weight ranks first, so a negative delta moves entries towards the root.
+//
+// The new weights saturate at math.MinInt and math.MaxInt instead of
+// overflowing.  An empty range (lo > hi) or a zero delta returns n unchanged.
+//
+// The range is split off, re-weighted and joined back.  Shifting every weight
+// of a subtree by the same amount, saturated or not, keeps it heap-ordered, so
+// only the k entries of the range are copied: O(k + log n).
+func (t *Treap) BoostRange(n *Node, lo, hi interface{}, delta int) *Node {
+	if delta == 0 || lo != Unbounded && hi != Unbounded && t.h().CompareKeys(lo, hi) > 0 {
+		return n
+	}
+
+	var left, first, mid, last, right *Node
+
+	rest := n
+	if lo != Unbounded {
+		left, first, rest = t.split(n, lo)
+	}
+	mid = rest
+	if hi != Unbounded {
+		mid, last, right = t.split(rest, hi)
+	}
+	if first != nil {
+		mid = t.join(t.clone(first, nil, nil), mid)
+	}
+	if last != nil {
+		mid = t.join(mid, t.clone(last, nil, nil))
+	}
+
+	return t.join(left, t.join(t.boost(mid, delta), right))
+}
+
+// boost returns a copy of n with delta added to every weight.
+func (t *Treap) boost(n *Node, delta int) *Node {
+	if n == nil {
+		return nil
+	}
+	res := t.clone(n, t.boost(n.Left, delta), t.boost(n.Right, delta))
+	res.Weight = boosted(n.Weight, delta)
+	return res
+}
+
+// boosted returns w+delta, saturated at math.MinInt and math.MaxInt.
+func boosted(w, delta int) int {
+	switch {
+	case delta > 0 && w > math.MaxInt-delta:
+		return math.MaxInt
+	case delta < 0 && w < math.MinInt-delta:
+		return math.MinInt
+	default:
+		return w + delta
+	}
+}
+
+// WeightStats summarizes the distribution of the weights of a treap.  Both
+// heavily duplicated weights and weights that follow the key order degrade a
+// treap towards a linked list, however good the rest of the distribution is.
+type WeightStats struct {
+	Count    int
+	Min, Max int
+	Mean     float64
+	StdDev   float64
+
+	// Distinct is the number of distinct weights.
+	Distinct int
+	// Rising is the fraction of pairs of consecutive keys whose weights
+	// increase: near 0 or 1 when the weights are monotone in the key order,
+	// around 0.5 for random weights.
+	Rising float64
+
+	// Duplicated and Monotone flag distributions that unbalance the treap.
+	Duplicated, Monotone bool
+}
+
+const (
+	// maxDuplicateFraction is the fraction of repeated weights above which
+	// WeightStats flags duplication.
+	maxDuplicateFraction = 0.1
+	// maxRisingSkew is how far from 0.5 Rising may be before the weights
+	// are flagged as monotone.
+	maxRisingSkew = 0.4
+)
+
+// Skewed reports whether the weights are likely to unbalance the treap.
+func (s WeightStats) Skewed() bool {
+	return s.Duplicated || s.Monotone
+}
+
+// Suggestion describes how to fix skewed weights, or is empty if the weights
+// look healthy.
+func (s WeightStats) Suggestion() string {
+	switch {
+	case s.Monotone:
+		return "weights follow the key order; use random weights (Put) or weights hashed from the keys"
+	case s.Duplicated:
+		return "many nodes share a weight; draw weights from a wider random range or hash the keys"
+	default:
+		return ""
+	}
+}
+
+// WeightStats computes the distribution statistics of the weights of n in a
+// single ordered pass.
+func (t *Treap) WeightStats(n *Node) WeightStats {
+	var (
+		s      WeightStats
+		m2     float64 // sum of squared deviations (Welford)
+		rising int
+		prev   *Node
+		seen   = make(map[int]struct{})
+	)
+
+	ascend(n, func(n *Node) bool {
+		w := n.Weight
+		if s.Count == 0 || w < s.Min {
+			s.Min = w
+		}
+		if s.Count == 0 || w > s.Max {
+			s.Max = w
+		}
+		s.Count++
+		delta := float64(w) - s.Mean
+		s.Mean += delta / float64(s.Count)
+		m2 += delta * (float64(w) - s.Mean)
+		seen[w] = struct{}{}
+
+		if prev != nil && t.h().CompareWeights(prev.Weight, w) < 0 {
+			rising++
+		}
+		prev = n
+		return true
+	})
+	if s.Count == 0 {
+		return s
+	}
+
+	s.Distinct = len(seen)
+	s.StdDev = math.Sqrt(m2 / float64(s.Count))
+	s.Duplicated = float64(s.Count-s.Distinct) > maxDuplicateFraction*float64(s.Count)
+
+	if s.Count > 1 {
+		s.Rising = float64(rising) / float64(s.Count-1)
+		s.Monotone = math.Abs(s.Rising-0.5) > maxRisingSkew
+	}
+	return s
+}
@@ -0,0 +1,153 @@
+package safe_treap
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// weighted builds a treap of the keys 0..n-1 whose weights are a fixed
+// random permutation of 1000..1000+n-1.
+func weighted(t *testing.T, n int) (*Treap, *Node) {
+	t.Helper()
+	tr := NewIntTreap()
+	var root *Node
+	for k, w := range rand.New(rand.NewSource(1)).Perm(n) {
+		root, _ = tr.Insert(root, k, k, 1000+w)
+	}
+	if err := tr.Validate(root); err != nil {
+		t.Fatal(err)
+	}
+	return tr, root
+}
+
+func TestAscendByWeight(t *testing.T) {
+	tr, root := weighted(t, 101)
+	prev := -1
+	tr.AscendByWeight(root, func(n *Node) bool {
+		if n.Weight <= prev {
+			t.Fatalf("weight %d visited after %d", n.Weight, prev)
+		}
+		prev = n.Weight
+		return n.Weight < 1050
+	})
+	if prev != 1050 {
+		t.Errorf("walk stopped at weight %d, want 1050", prev)
+	}
+}
+
+func TestSplitByWeight(t *testing.T) {
+	tr, root := weighted(t, 101)
+	below, above := tr.SplitByWeight(root, 1030)
+	for _, half := range []*Node{below, above} {
+		if err := tr.Validate(half); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tr.Len(below) != 30 || tr.Len(above) != 71 {
+		t.Fatalf("split into %d and %d entries", tr.Len(below), tr.Len(above))
+	}
+	ascend(below, func(n *Node) bool {
+		if n.Weight >= 1030 {
+			t.Errorf("key %d of weight %d below the threshold", n.Key, n.Weight)
+		}
+		return true
+	})
+}
+
+func TestBoostRange(t *testing.T) {
+	tr, root := weighted(t, 101)
+	boosted := tr.BoostRange(root, 40, 60, -500)
+	if err := tr.Validate(boosted); err != nil {
+		t.Fatal(err)
+	}
+	for k := 0; k < 101; k++ {
+		n, _ := tr.GetNode(boosted, k)
+		old, _ := tr.GetNode(root, k)
+		want := old.Weight
+		if k >= 40 && k <= 60 {
+			want -= 500
+		}
+		if n.Weight != want {
+			t.Errorf("key %d has weight %d, want %d", k, n.Weight, want)
+		}
+	}
+	if k := boosted.Key.(int); k < 40 || k > 60 {
+		t.Errorf("root %d is not in the boosted range", k)
+	}
+}
+
+func TestBoostRangeEdges(t *testing.T) {
+	tr, root := weighted(t, 101)
+	if got := tr.BoostRange(root, 60, 40, -1000); got != root {
+		t.Error("an empty range changed the treap")
+	}
+
+	down := tr.BoostRange(root, Unbounded, 10, -2000)
+	if err := tr.Validate(down); err != nil {
+		t.Fatal(err)
+	}
+	old, _ := tr.GetNode(root, 5)
+	if n, _ := tr.GetNode(down, 5); n.Weight != old.Weight-2000 {
+		t.Errorf("weight went down to %d, want %d", n.Weight, old.Weight-2000)
+	}
+	if got := tr.BoostRange(root, Unbounded, Unbounded, 0); got != root {
+		t.Error("a zero delta changed the treap")
+	}
+
+	up := tr.BoostRange(root, 90, Unbounded, math.MaxInt)
+	if err := tr.Validate(up); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := tr.GetNode(up, 100); n.Weight != math.MaxInt {
+		t.Errorf("weight overflowed to %d", n.Weight)
+	}
+}
+
+func TestBoostNegativeWeights(t *testing.T) {
+	tr := NewIntTreap()
+	var root *Node
+	for k, w := range []int{-10, -5, 3, math.MinInt + 2} {
+		root, _ = tr.Insert(root, k, k, w)
+	}
+
+	up := tr.BoostRange(root, 0, 1, 1)
+	down := tr.BoostRange(root, 3, 3, -5)
+	for _, tc := range []struct {
+		root      *Node
+		key, want int
+	}{
+		{up, 0, -9}, {up, 1, -4}, {up, 2, 3},
+		{down, 3, math.MinInt}, {down, 0, -10},
+	} {
+		if err := tr.Validate(tc.root); err != nil {
+			t.Fatal(err)
+		}
+		if n, _ := tr.GetNode(tc.root, tc.key); n.Weight != tc.want {
+			t.Errorf("key %d has weight %d, want %d", tc.key, n.Weight, tc.want)
+		}
+	}
+}
+
+func TestWeightStats(t *testing.T) {
+	tr, root := weighted(t, 101)
+	if s := tr.WeightStats(root); s.Count != 101 || s.Distinct != 101 || s.Min != 1000 || s.Max != 1100 || s.Skewed() {
+		t.Errorf("WeightStats of a permutation = %+v", s)
+	}
+
+	var seq *Node
+	for k := 0; k < 100; k++ {
+		seq, _ = tr.Insert(seq, k, k, k)
+	}
+	if s := tr.WeightStats(seq); !s.Monotone || s.Suggestion() == "" {
+		t.Errorf("WeightStats of increasing weights = %+v", s)
+	}
+
+	var dup *Node
+	for k := 0; k < 100; k++ {
+		dup, _ = tr.Insert(dup, k, k, k%3)
+	}
+	if s := tr.WeightStats(dup); !s.Duplicated {
+		t.Errorf("WeightStats of duplicated weights = %+v", s)
+	}
+}
@@ -0,0 +1,98 @@
+package safe_treap
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrClosed is the misuse of committing to a WriteBehind or applying to a
+// ChangeFeed after Close (see MisusePolicy).
+var ErrClosed = errors.New("write-behind persister is closed")
+
+// WriteBehind persists committed roots on a background goroutine, so the
+// latency of the caller's mutations does not depend on the latency of the
+// storage behind persist.
+//
+// Roots are immutable, so queueing one costs a pointer: the persister sees
+// exactly the version that was committed, whatever happens to the treap
+// afterwards.  Roots are persisted one at a time in the order they were
+// committed.
+type WriteBehind struct {
+	persist func(root *Node) error
+	queue   chan *Node
+	done    chan struct{}
+
+	mu     sync.Mutex // serializes Commit and Close
+	closed bool
+
+	errMu sync.Mutex
+	err   error
+}
+
+// NewWriteBehind starts a persister that calls persist for every committed
+// root.  Up to capacity roots may be waiting to be persisted; once the queue
+// is full Commit blocks, which applies backpressure to writers that outrun the
+// storage.
+func NewWriteBehind(capacity int, persist func(root *Node) error) *WriteBehind {
+	w := &WriteBehind{
+		persist: persist,
+		queue:   make(chan *Node, capacity),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *WriteBehind) run() {
+	defer close(w.done)
+	for root := range w.queue {
+		if err := w.persist(root); err != nil {
+			w.errMu.Lock()
+			if w.err == nil {
+				w.err = err
+			}
+			w.errMu.Unlock()
+		}
+	}
+}
+
+// Commit queues root to be persisted.  It blocks while the queue is full and
+// returns the first error reported by persist so far, if any, in which case
+// root is not queued.
+func (w *WriteBehind) Commit(root *Node) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return misuse(ErrClosed)
+	}
+	if err := w.Err(); err != nil {
+		return err
+	}
+
+	// holding mu while blocked keeps Close from closing the queue under a
+	// pending send; run never takes it.
+	w.queue <- root
+	return nil
+}
+
+// Err returns the first error reported by persist, if any.
+func (w *WriteBehind) Err() error {
+	w.errMu.Lock()
+	defer w.errMu.Unlock()
+	return w.err
+}
+
+// Close waits for every queued root to be persisted and stops the background
+// goroutine.  It returns the first error reported by persist.
+func (w *WriteBehind) Close() error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	<-w.done
+	return w.Err()
+}
@@ -0,0 +1,95 @@
+package safe_treap
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWriteBehind(t *testing.T) {
+	tr := NewIntTreap()
+	var persisted []*Node
+	w := NewWriteBehind(4, func(root *Node) error {
+		persisted = append(persisted, root)
+		return nil
+	})
+
+	var roots []*Node
+	var root *Node
+	for k := 0; k < 20; k++ {
+		root, _ = tr.Put(root, k, k)
+		roots = append(roots, root)
+		if err := w.Commit(root); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(persisted) != len(roots) {
+		t.Fatalf("persisted %d roots, committed %d", len(persisted), len(roots))
+	}
+	for i := range roots {
+		if persisted[i] != roots[i] || tr.Len(persisted[i]) != i+1 {
+			t.Fatalf("root %d was persisted out of order", i)
+		}
+	}
+
+	if err := w.Commit(root); err != ErrClosed {
+		t.Errorf("Commit after Close: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestWriteBehindBackpressure(t *testing.T) {
+	release := make(chan struct{})
+	w := NewWriteBehind(1, func(*Node) error {
+		<-release
+		return nil
+	})
+	defer w.Close()
+
+	w.Commit(nil) // taken by the persister
+	waitFor(t, func() bool { return len(w.queue) == 0 })
+	w.Commit(nil) // fills the queue
+
+	committed := make(chan struct{})
+	go func() {
+		w.Commit(nil)
+		close(committed)
+	}()
+	select {
+	case <-committed:
+		t.Fatal("Commit did not block on a full queue")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	<-committed
+}
+
+func TestWriteBehindErrors(t *testing.T) {
+	errDisk := errors.New("disk full")
+	calls := 0
+	w := NewWriteBehind(8, func(*Node) error {
+		calls++
+		if calls == 1 {
+			return errDisk
+		}
+		return errors.New("later failure")
+	})
+	w.Commit(nil)
+	waitFor(t, func() bool { return w.Err() != nil })
+
+	if err := w.Commit(nil); err != errDisk {
+		t.Errorf("Commit after a failure: %v", err)
+	}
+	if err := w.Close(); err != errDisk {
+		t.Errorf("Close returned %v, want the first error", err)
+	}
+	if calls != 1 {
+		t.Errorf("a root was persisted after the failure was reported")
+	}
+}